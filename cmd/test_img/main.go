@@ -11,9 +11,12 @@ func main() {
 	m := model.NewSequential()
 	m.AddLayer(layer.NewInput(5, 5, 5))
 	m.AddLayer(layer.NewDeconv2D(10, 3, 3, 1, activation.NewTanh()))
-	m.AddLayer(layer.NewDeconv2D(10, 5, 5, 2, activation.NewTanh()))
-	m.AddLayer(layer.NewDeconv2D(10, 5, 5, 2, activation.NewTanh()))
-	m.AddLayer(layer.NewDeconv2D(3, 5, 5, 2, activation.NewTanh()))
+	m.AddLayer(layer.NewUpSampling2D(2, 2, layer.InterpolationBilinear))
+	m.AddLayer(layer.NewDeconv2D(10, 5, 5, 1, activation.NewTanh()))
+	m.AddLayer(layer.NewUpSampling2D(2, 2, layer.InterpolationBilinear))
+	m.AddLayer(layer.NewDeconv2D(10, 5, 5, 1, activation.NewTanh()))
+	m.AddLayer(layer.NewUpSampling2D(2, 2, layer.InterpolationNearest))
+	m.AddLayer(layer.NewConv2D(3, 5, 5, 1, activation.NewTanh()))
 
 	in := tensor.NewRandTensor(0, 1, 5, 5, 5)
 	out, _ := m.Predict(in)
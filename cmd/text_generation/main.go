@@ -16,7 +16,7 @@ import (
 )
 
 const (
-	Symbols  = string(1) + "abcdefghijklmnopqrstuvwxyz0123456789 "
+	Symbols  = string(rune(1)) + "abcdefghijklmnopqrstuvwxyz0123456789 "
 	InSize   = len(Symbols)
 	Epochs   = 100
 	Alpha    = 0.001
@@ -76,6 +76,7 @@ func CharFromTensor(t tensor.Tensor) byte {
 
 func GetModel() model.Model {
 	m := model.NewSequential()
+	m.ClipGlobalNorm = 5
 	m.AddLayer(layer.NewInDense(InSize, 10, activation.NewTanh()))
 	m.AddLayer(layer.NewRecurrent2(30, activation.NewTanh()))
 	m.AddLayer(layer.NewRecurrent2(30, activation.NewTanh()))
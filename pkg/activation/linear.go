@@ -15,3 +15,5 @@ func (linear *Linear) Activate(input tensor.Tensor) (tensor.Tensor, error) {
 func (linear *Linear) Derive(input tensor.Tensor) (tensor.Tensor, error) {
 	return tensor.NewOneTensor(input.GetShape()...), nil
 }
+
+var ActLinear = NewLinear()
@@ -0,0 +1,75 @@
+package callback
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/model"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+)
+
+// ModelCheckpoint saves a checkpoint of the model at the end of every
+// epoch, in JSON if Path ends with ".json" and in the binary format
+// otherwise. A "%d" in Path is replaced by the epoch. With BestOnly it is
+// only saved when the Monitor log improves, decreasing or, if Max is set,
+// increasing.
+type ModelCheckpoint struct {
+	Path     string
+	Monitor  string
+	BestOnly bool
+	Max      bool
+
+	model.BaseCallback
+
+	m       model.Model
+	best    float64
+	started bool
+}
+
+func NewModelCheckpoint(path string) *ModelCheckpoint {
+	return &ModelCheckpoint{
+		Path:    path,
+		Monitor: "loss",
+	}
+}
+
+func NewBestModelCheckpoint(path, monitor string, max bool) *ModelCheckpoint {
+	return &ModelCheckpoint{
+		Path:     path,
+		Monitor:  monitor,
+		BestOnly: true,
+		Max:      max,
+	}
+}
+
+func (mc *ModelCheckpoint) OnTrainBegin(m model.Model, logs model.Logs) error {
+	mc.m = m
+	return nil
+}
+
+func (mc *ModelCheckpoint) OnEpochEnd(epoch int, logs model.Logs) error {
+	if mc.BestOnly {
+		value, ok := logs[mc.Monitor]
+		if !ok || math.IsNaN(value) {
+			return nil
+		}
+		if mc.started && (mc.Max && value <= mc.best || !mc.Max && value >= mc.best) {
+			return nil
+		}
+		mc.best = value
+		mc.started = true
+	}
+	c, err := mc.m.GetCheckpoint()
+	if err != nil {
+		return err
+	}
+	path := mc.Path
+	if strings.Contains(path, "%d") {
+		path = fmt.Sprintf(path, epoch)
+	}
+	if strings.HasSuffix(path, ".json") {
+		return serialization.JsonSaveCheckpoint(c, path)
+	}
+	return serialization.BinSaveCheckpoint(c, path)
+}
@@ -0,0 +1,92 @@
+package callback
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/model"
+)
+
+// CSVLogger writes the logs of every epoch as a row of a CSV file, headed
+// by the epoch and the names of the logs of the first one. With Append the
+// rows are added to the file instead of replacing it, without a new header
+// if it is not empty.
+type CSVLogger struct {
+	Path      string
+	Separator rune
+	Append    bool
+
+	model.BaseCallback
+
+	file   *os.File
+	writer *csv.Writer
+	keys   []string
+	header bool
+}
+
+func NewCSVLogger(path string) *CSVLogger {
+	return &CSVLogger{
+		Path:      path,
+		Separator: ',',
+	}
+}
+
+func (cl *CSVLogger) OnTrainBegin(m model.Model, logs model.Logs) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if cl.Append {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(cl.Path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	cl.file = file
+	cl.writer = csv.NewWriter(file)
+	cl.writer.Comma = cl.Separator
+	cl.keys = nil
+	cl.header = info.Size() == 0
+	return nil
+}
+
+func (cl *CSVLogger) OnEpochEnd(epoch int, logs model.Logs) error {
+	if cl.keys == nil {
+		cl.keys = make([]string, 0, len(logs))
+		for k := range logs {
+			cl.keys = append(cl.keys, k)
+		}
+		sort.Strings(cl.keys)
+		if cl.header {
+			err := cl.writer.Write(append([]string{"epoch"}, cl.keys...))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	row := []string{strconv.Itoa(epoch)}
+	for _, k := range cl.keys {
+		row = append(row, strconv.FormatFloat(logs[k], 'g', -1, 64))
+	}
+	err := cl.writer.Write(row)
+	if err != nil {
+		return err
+	}
+	cl.writer.Flush()
+	return cl.writer.Error()
+}
+
+func (cl *CSVLogger) OnTrainEnd(logs model.Logs) error {
+	if cl.file == nil {
+		return nil
+	}
+	cl.writer.Flush()
+	err := cl.file.Close()
+	cl.file = nil
+	return err
+}
@@ -0,0 +1,89 @@
+package callback
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/model"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+)
+
+// EarlyStopping stops the training when the Monitor log of the epochs has
+// not improved by more than MinDelta for Patience epochs. The log improves
+// when it decreases or, if Max is set, when it increases. With RestoreBest
+// the model gets back the weights of its best epoch when it stops.
+type EarlyStopping struct {
+	Monitor     string
+	MinDelta    float64
+	Patience    int
+	Max         bool
+	RestoreBest bool
+
+	// StoppedEpoch is the epoch the training was stopped at, -1 if it was
+	// not stopped.
+	StoppedEpoch int
+
+	model.BaseCallback
+
+	m       model.Model
+	best    float64
+	started bool
+	wait    int
+	weights serialization.Weights
+}
+
+func NewEarlyStopping(monitor string, patience int) *EarlyStopping {
+	return &EarlyStopping{
+		Monitor:      monitor,
+		Patience:     patience,
+		StoppedEpoch: -1,
+	}
+}
+
+func (es *EarlyStopping) OnTrainBegin(m model.Model, logs model.Logs) error {
+	es.m = m
+	es.started = false
+	es.wait = 0
+	es.StoppedEpoch = -1
+	es.weights = serialization.Weights{}
+	return nil
+}
+
+func (es *EarlyStopping) improved(value float64) bool {
+	if !es.started {
+		return true
+	}
+	if es.Max {
+		return value > es.best+es.MinDelta
+	}
+	return value < es.best-es.MinDelta
+}
+
+func (es *EarlyStopping) OnEpochEnd(epoch int, logs model.Logs) error {
+	value, ok := logs[es.Monitor]
+	if !ok || math.IsNaN(value) {
+		return nil
+	}
+	if es.improved(value) {
+		es.best = value
+		es.started = true
+		es.wait = 0
+		if es.RestoreBest {
+			w, err := es.m.GetModelWeights()
+			if err != nil {
+				return err
+			}
+			es.weights = w.Copy()
+		}
+		return nil
+	}
+	es.wait++
+	if es.wait < es.Patience {
+		return nil
+	}
+	es.StoppedEpoch = epoch
+	es.m.StopTraining()
+	if es.RestoreBest && es.started {
+		return es.m.SetModelWeights(es.weights)
+	}
+	return nil
+}
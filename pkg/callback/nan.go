@@ -0,0 +1,32 @@
+package callback
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/model"
+)
+
+// TerminateOnNaN stops the training as soon as the loss of a batch is NaN
+// or infinite.
+type TerminateOnNaN struct {
+	model.BaseCallback
+
+	m model.Model
+}
+
+func NewTerminateOnNaN() *TerminateOnNaN {
+	return &TerminateOnNaN{}
+}
+
+func (tn *TerminateOnNaN) OnTrainBegin(m model.Model, logs model.Logs) error {
+	tn.m = m
+	return nil
+}
+
+func (tn *TerminateOnNaN) OnBatchEnd(batch int, logs model.Logs) error {
+	loss := logs["loss"]
+	if math.IsNaN(loss) || math.IsInf(loss, 0) {
+		tn.m.StopTraining()
+	}
+	return nil
+}
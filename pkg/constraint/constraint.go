@@ -0,0 +1,91 @@
+package constraint
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Constraint changes the weights of a layer in place after every update.
+type Constraint interface {
+	Apply(w tensor.Tensor)
+}
+
+// units splits the values of w in the ones of every unit, the slices along
+// its first axis, or a single one if w is a vector.
+func units(w tensor.Tensor) [][]float64 {
+	data := w.GetData()
+	shape := w.GetShape()
+	if len(shape) < 2 || shape[0] < 1 {
+		return [][]float64{data}
+	}
+	size := len(data) / shape[0]
+	out := make([][]float64, shape[0])
+	for i := range out {
+		out[i] = data[i*size : (i+1)*size]
+	}
+	return out
+}
+
+func norm(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v * v
+	}
+	return math.Sqrt(sum)
+}
+
+// MaxNorm scales down the weights of every unit whose norm is bigger than
+// Max.
+type MaxNorm struct {
+	Max float64
+}
+
+func NewMaxNorm(max float64) *MaxNorm {
+	return &MaxNorm{max}
+}
+
+func (c *MaxNorm) Apply(w tensor.Tensor) {
+	for _, unit := range units(w) {
+		n := norm(unit)
+		if n > c.Max {
+			for i := range unit {
+				unit[i] *= c.Max / n
+			}
+		}
+	}
+}
+
+// UnitNorm scales the weights of every unit to a norm of 1.
+type UnitNorm struct{}
+
+func NewUnitNorm() *UnitNorm {
+	return &UnitNorm{}
+}
+
+func (c *UnitNorm) Apply(w tensor.Tensor) {
+	for _, unit := range units(w) {
+		n := norm(unit)
+		if n > 0 {
+			for i := range unit {
+				unit[i] /= n
+			}
+		}
+	}
+}
+
+// NonNeg sets the negative weights to zero.
+type NonNeg struct{}
+
+func NewNonNeg() *NonNeg {
+	return &NonNeg{}
+}
+
+func (c *NonNeg) Apply(w tensor.Tensor) {
+	data := w.GetData()
+	for i, v := range data {
+		if v < 0 {
+			data[i] = 0
+		}
+	}
+}
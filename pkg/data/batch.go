@@ -0,0 +1,130 @@
+package data
+
+import (
+	"errors"
+	"io"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// batched stacks the samples of a dataset in groups.
+type batched struct {
+	ds       Dataset
+	size     int
+	dropLast bool
+}
+
+// Batch returns a dataset whose samples stack size samples of ds along a
+// new first axis, so an input of shape [w, h] becomes [size, w, h]. The
+// last batch is smaller if the samples are not enough, or dropped if
+// dropLast is set. The models train one sample at a time, so Train needs
+// the samples of Unbatch.
+func Batch(ds Dataset, size int, dropLast bool) Dataset {
+	return &batched{ds, size, dropLast}
+}
+
+func (b *batched) Iter() Iterator {
+	return &batchIter{Iterator: b.ds.Iter(), size: b.size, dropLast: b.dropLast}
+}
+
+type batchIter struct {
+	Iterator
+	size     int
+	dropLast bool
+	ended    bool
+}
+
+// stack joins tensors of the same shape along a new first axis.
+func stack(ts []tensor.Tensor) (tensor.Tensor, error) {
+	shape := ts[0].GetShape()
+	data := make([]float64, 0, len(ts)*ts[0].Size())
+	for _, t := range ts {
+		if !tensor.CompareShape(shape, t.GetShape()) {
+			return nil, errors.New("the samples of a batch have different shapes")
+		}
+		data = append(data, t.GetData()...)
+	}
+	return tensor.NewTensor(data, append([]int{len(ts)}, shape...)...), nil
+}
+
+func (it *batchIter) Next() (Sample, error) {
+	var inputs, targets []tensor.Tensor
+	for !it.ended && len(inputs) < it.size {
+		s, err := it.Iterator.Next()
+		if err == io.EOF {
+			it.ended = true
+			break
+		}
+		if err != nil {
+			return Sample{}, err
+		}
+		inputs = append(inputs, s.Input)
+		targets = append(targets, s.Target)
+	}
+	if len(inputs) == 0 || it.dropLast && len(inputs) < it.size {
+		return Sample{}, io.EOF
+	}
+	input, err := stack(inputs)
+	if err != nil {
+		return Sample{}, err
+	}
+	target, err := stack(targets)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{Input: input, Target: target}, nil
+}
+
+// unbatched splits the samples of a dataset along their first axis.
+type unbatched struct {
+	ds Dataset
+}
+
+// Unbatch returns a dataset with the samples stacked in the samples of ds,
+// the inverse of Batch.
+func Unbatch(ds Dataset) Dataset {
+	return &unbatched{ds}
+}
+
+func (u *unbatched) Iter() Iterator {
+	return &unbatchIter{Iterator: u.ds.Iter()}
+}
+
+type unbatchIter struct {
+	Iterator
+	batch Sample
+	index int
+	size  int
+	ended bool
+}
+
+func (it *unbatchIter) Next() (Sample, error) {
+	for it.index >= it.size {
+		if it.ended {
+			return Sample{}, io.EOF
+		}
+		s, err := it.Iterator.Next()
+		if err == io.EOF {
+			it.ended = true
+		}
+		if err != nil {
+			return Sample{}, err
+		}
+		if s.Input.ShapeAt(0) != s.Target.ShapeAt(0) {
+			return Sample{}, errors.New("inputs and targets batches are different")
+		}
+		it.batch = s
+		it.index = 0
+		it.size = s.Input.ShapeAt(0)
+	}
+	input, err := it.batch.Input.GetSubTensor(it.index)
+	if err != nil {
+		return Sample{}, err
+	}
+	target, err := it.batch.Target.GetSubTensor(it.index)
+	if err != nil {
+		return Sample{}, err
+	}
+	it.index++
+	return Sample{Input: input, Target: target}, nil
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"errors"
+	"io"
+)
+
+// cached keeps the samples of a streamed dataset in memory after the first
+// full iteration.
+type cached struct {
+	ds      Dataset
+	samples []Sample
+	done    bool
+}
+
+// Cache returns a dataset that reads the samples of ds once and keeps them
+// in memory. Streamed datasets are kept after the first iteration that
+// reaches their end, and Indexed ones sample by sample.
+func Cache(ds Dataset) Dataset {
+	if indexed, ok := ds.(Indexed); ok {
+		return &cachedIndexed{
+			indexed: indexed,
+			samples: make([]Sample, indexed.Len()),
+			loaded:  make([]bool, indexed.Len()),
+		}
+	}
+	return &cached{ds: ds}
+}
+
+func (c *cached) Iter() Iterator {
+	if c.done {
+		return &sampleIter{samples: c.samples}
+	}
+	return &cacheIter{Iterator: c.ds.Iter(), c: c}
+}
+
+// sampleIter iterates samples in memory.
+type sampleIter struct {
+	samples []Sample
+	index   int
+}
+
+func (it *sampleIter) Next() (Sample, error) {
+	if it.index >= len(it.samples) {
+		return Sample{}, io.EOF
+	}
+	it.index++
+	return it.samples[it.index-1], nil
+}
+
+func (it *sampleIter) Close() error {
+	return nil
+}
+
+// cacheIter keeps the samples it reads and gives them to its dataset when
+// it reaches the end.
+type cacheIter struct {
+	Iterator
+	c       *cached
+	samples []Sample
+}
+
+func (it *cacheIter) Next() (Sample, error) {
+	s, err := it.Iterator.Next()
+	if err == io.EOF && !it.c.done {
+		it.c.samples = it.samples
+		it.c.done = true
+	}
+	if err != nil {
+		return Sample{}, err
+	}
+	it.samples = append(it.samples, s)
+	return s, nil
+}
+
+// cachedIndexed keeps every sample of an Indexed dataset after reading it.
+type cachedIndexed struct {
+	indexed Indexed
+	samples []Sample
+	loaded  []bool
+}
+
+func (c *cachedIndexed) Len() int {
+	return len(c.samples)
+}
+
+func (c *cachedIndexed) Get(i int) (Sample, error) {
+	if i < 0 || i >= len(c.samples) {
+		return Sample{}, errors.New("index out of range")
+	}
+	if !c.loaded[i] {
+		s, err := c.indexed.Get(i)
+		if err != nil {
+			return Sample{}, err
+		}
+		c.samples[i] = s
+		c.loaded[i] = true
+	}
+	return c.samples[i], nil
+}
+
+func (c *cachedIndexed) Iter() Iterator {
+	return &indexedIter{ds: c}
+}
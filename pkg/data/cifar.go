@@ -0,0 +1,104 @@
+package data
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+const (
+	cifarSize   = 32
+	cifarPixels = cifarSize * cifarSize
+)
+
+// readCIFAR reads records of labelBytes labels followed by the 32x32 red,
+// green and blue planes of an image, until the end of r. The label at
+// label is the class of the record.
+func readCIFAR(r io.Reader, labelBytes, label, classes int) ([]tensor.Tensor, []tensor.Tensor, error) {
+	r = bufio.NewReader(r)
+	record := make([]byte, labelBytes+3*cifarPixels)
+	var inputs, targets []tensor.Tensor
+	for {
+		_, err := io.ReadFull(r, record)
+		if err == io.EOF {
+			return inputs, targets, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil, errors.New("truncated cifar record")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		target, err := oneHot(int(record[label]), classes)
+		if err != nil {
+			return nil, nil, err
+		}
+		pixels := record[labelBytes:]
+		img := tensor.NewZeroTensor(cifarSize, cifarSize, 3)
+		for c := 0; c < 3; c++ {
+			for y := 0; y < cifarSize; y++ {
+				for x := 0; x < cifarSize; x++ {
+					img.Set(float64(pixels[c*cifarPixels+y*cifarSize+x])/255.0, x, y, c)
+				}
+			}
+		}
+		inputs = append(inputs, img)
+		targets = append(targets, target)
+	}
+}
+
+// ReadCIFAR10 reads a file in the binary format of CIFAR-10 as [32, 32, 3]
+// images, with the pixels scaled by 1/255, and one-hot targets of 10
+// classes.
+func ReadCIFAR10(r io.Reader) ([]tensor.Tensor, []tensor.Tensor, error) {
+	return readCIFAR(r, 1, 0, 10)
+}
+
+// ReadCIFAR100 reads a file in the binary format of CIFAR-100 like
+// ReadCIFAR10, with one-hot targets of its 100 fine classes, or of its 20
+// coarse ones if fine is false.
+func ReadCIFAR100(r io.Reader, fine bool) ([]tensor.Tensor, []tensor.Tensor, error) {
+	if fine {
+		return readCIFAR(r, 2, 1, 100)
+	}
+	return readCIFAR(r, 2, 0, 20)
+}
+
+// loadCIFAR reads every file with read into a single dataset.
+func loadCIFAR(read func(io.Reader) ([]tensor.Tensor, []tensor.Tensor, error), paths []string) (*Slice, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no cifar files")
+	}
+	var inputs, targets []tensor.Tensor
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in, tg, err := read(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, in...)
+		targets = append(targets, tg...)
+	}
+	return NewSlice(inputs, targets)
+}
+
+// LoadCIFAR10 reads CIFAR-10 batch files, such as "data_batch_1.bin" to
+// "data_batch_5.bin", as a single dataset.
+func LoadCIFAR10(paths ...string) (*Slice, error) {
+	return loadCIFAR(ReadCIFAR10, paths)
+}
+
+// LoadCIFAR100 reads CIFAR-100 files, such as "train.bin", as a single
+// dataset.
+func LoadCIFAR100(fine bool, paths ...string) (*Slice, error) {
+	return loadCIFAR(func(r io.Reader) ([]tensor.Tensor, []tensor.Tensor, error) {
+		return ReadCIFAR100(r, fine)
+	}, paths)
+}
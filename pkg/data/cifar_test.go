@@ -0,0 +1,97 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// cifarFixture returns a record of the given labels with pixel values that
+// depend on their channel, row and column.
+func cifarFixture(labels ...byte) []byte {
+	record := append([]byte{}, labels...)
+	for c := 0; c < 3; c++ {
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				record = append(record, cifarPixel(x, y, c))
+			}
+		}
+	}
+	return record
+}
+
+func cifarPixel(x, y, c int) byte {
+	return byte(c*80 + y*2 + x%2)
+}
+
+func TestReadCIFAR10Layout(t *testing.T) {
+	var file []byte
+	file = append(file, cifarFixture(7)...)
+	file = append(file, cifarFixture(2)...)
+	inputs, targets, err := ReadCIFAR10(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 2 || len(targets) != 2 {
+		t.Fatalf("%d inputs and %d targets, want 2", len(inputs), len(targets))
+	}
+	for i, class := range []int{7, 2} {
+		if !tensor.CompareShape(inputs[i].GetShape(), []int{32, 32, 3}) {
+			t.Fatalf("shape %v, want [32 32 3]", inputs[i].GetShape())
+		}
+		for _, p := range [][3]int{{0, 0, 0}, {1, 5, 0}, {31, 2, 1}, {4, 31, 2}} {
+			v, _ := inputs[i].Get(p[0], p[1], p[2])
+			want := float64(cifarPixel(p[0], p[1], p[2])) / 255
+			if v != want {
+				t.Fatalf("pixel %v is %v, want %v", p, v, want)
+			}
+		}
+		checkOneHot(t, targets[i], class, 10)
+	}
+}
+
+func TestReadCIFAR100Labels(t *testing.T) {
+	file := append(cifarFixture(4, 61), cifarFixture(19, 99)...)
+	_, fine, err := ReadCIFAR100(bytes.NewReader(file), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOneHot(t, fine[0], 61, 100)
+	checkOneHot(t, fine[1], 99, 100)
+
+	_, coarse, err := ReadCIFAR100(bytes.NewReader(file), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOneHot(t, coarse[0], 4, 20)
+	checkOneHot(t, coarse[1], 19, 20)
+}
+
+func TestReadCIFARInvalid(t *testing.T) {
+	if _, _, err := ReadCIFAR10(bytes.NewReader(cifarFixture(1)[:100])); err == nil {
+		t.Error("expected an error for a truncated record")
+	}
+	if _, _, err := ReadCIFAR10(bytes.NewReader(cifarFixture(10))); err == nil {
+		t.Error("expected an error for a label out of range")
+	}
+}
+
+func TestLoadCIFAR10(t *testing.T) {
+	first := writeFixture(t, "data_batch_1.bin", cifarFixture(1))
+	second := writeFixture(t, "data_batch_2.bin", append(cifarFixture(2), cifarFixture(3)...))
+	ds, err := LoadCIFAR10(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 3 {
+		t.Fatalf("%d samples, want 3", ds.Len())
+	}
+	for i, class := range []int{1, 2, 3} {
+		s, _ := ds.Get(i)
+		checkOneHot(t, s.Target, class, 10)
+	}
+	if _, err := LoadCIFAR10(); err == nil {
+		t.Error("expected an error without files")
+	}
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Table is a tabular file in memory, the names of its columns and its rows
+// of text values.
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// ReadCSV reads a table with the given separator. Without a header the
+// columns are named by their position, from "0".
+func ReadCSV(r io.Reader, header bool, separator rune) (*Table, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = separator
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{}
+	if header {
+		if len(rows) == 0 {
+			return nil, errors.New("the file has no header")
+		}
+		t.Columns = rows[0]
+		rows = rows[1:]
+	} else if len(rows) > 0 {
+		t.Columns = make([]string, len(rows[0]))
+		for i := range t.Columns {
+			t.Columns[i] = strconv.Itoa(i)
+		}
+	}
+	t.Rows = rows
+	return t, nil
+}
+
+// LoadCSV reads a table from a file.
+func LoadCSV(path string, header bool, separator rune) (*Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCSV(file, header, separator)
+}
+
+// Index returns the position of a column, -1 if the table has not it.
+func (t *Table) Index(column string) int {
+	for i, c := range t.Columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// Column returns the values of a column.
+func (t *Table) Column(column string) ([]string, error) {
+	i := t.Index(column)
+	if i < 0 {
+		return nil, errors.New("unknown column " + column)
+	}
+	values := make([]string, len(t.Rows))
+	for r, row := range t.Rows {
+		values[r] = row[i]
+	}
+	return values, nil
+}
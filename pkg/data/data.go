@@ -0,0 +1,169 @@
+// Package data has datasets of samples for Train, from memory or streamed,
+// and transforms to compose them. The iterators of a dataset are not safe
+// for concurrent use, but a dataset can be iterated many times, one
+// iterator per epoch.
+package data
+
+import (
+	"errors"
+	"io"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Sample is an input of a model with its target.
+type Sample struct {
+	Input  tensor.Tensor
+	Target tensor.Tensor
+}
+
+// Iterator gives the samples of a dataset one by one. Next returns io.EOF
+// after the last one. Close releases the resources of the iterator, and
+// must be called even if it is not exhausted.
+type Iterator interface {
+	Next() (Sample, error)
+	Close() error
+}
+
+// Dataset is a source of samples. Every call to Iter starts a new pass over
+// them.
+type Dataset interface {
+	Iter() Iterator
+}
+
+// Indexed is a dataset with random access to its Len samples.
+type Indexed interface {
+	Dataset
+	Len() int
+	Get(i int) (Sample, error)
+}
+
+// Len returns the number of samples of a dataset that knows it, like the
+// Indexed ones, or -1 if the dataset is streamed.
+func Len(ds Dataset) int {
+	if sized, ok := ds.(interface{ Len() int }); ok {
+		return sized.Len()
+	}
+	return -1
+}
+
+// Collect reads all the samples of a dataset into memory.
+func Collect(ds Dataset) ([]tensor.Tensor, []tensor.Tensor, error) {
+	it := ds.Iter()
+	defer it.Close()
+	var inputs, targets []tensor.Tensor
+	for {
+		s, err := it.Next()
+		if err == io.EOF {
+			return inputs, targets, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, s.Input)
+		targets = append(targets, s.Target)
+	}
+}
+
+// indexedIter iterates an Indexed dataset in order.
+type indexedIter struct {
+	ds    Indexed
+	index int
+}
+
+func (it *indexedIter) Next() (Sample, error) {
+	if it.index >= it.ds.Len() {
+		return Sample{}, io.EOF
+	}
+	it.index++
+	return it.ds.Get(it.index - 1)
+}
+
+func (it *indexedIter) Close() error {
+	return nil
+}
+
+// Slice is a dataset of inputs and targets in memory.
+type Slice struct {
+	Inputs  []tensor.Tensor
+	Targets []tensor.Tensor
+}
+
+func NewSlice(inputs, targets []tensor.Tensor) (*Slice, error) {
+	if len(inputs) != len(targets) {
+		return nil, errors.New("inputs and targets len are different")
+	}
+	return &Slice{
+		Inputs:  inputs,
+		Targets: targets,
+	}, nil
+}
+
+func (s *Slice) Len() int {
+	return len(s.Inputs)
+}
+
+func (s *Slice) Get(i int) (Sample, error) {
+	if i < 0 || i >= len(s.Inputs) {
+		return Sample{}, errors.New("index out of range")
+	}
+	return Sample{Input: s.Inputs[i], Target: s.Targets[i]}, nil
+}
+
+func (s *Slice) Iter() Iterator {
+	return &indexedIter{ds: s}
+}
+
+// Func is an Indexed dataset of Length samples made by a function, which
+// can load them from disk when they are requested.
+type Func struct {
+	Length int
+	Sample func(i int) (Sample, error)
+}
+
+func NewFunc(length int, sample func(i int) (Sample, error)) *Func {
+	return &Func{
+		Length: length,
+		Sample: sample,
+	}
+}
+
+func (f *Func) Len() int {
+	return f.Length
+}
+
+func (f *Func) Get(i int) (Sample, error) {
+	if i < 0 || i >= f.Length {
+		return Sample{}, errors.New("index out of range")
+	}
+	return f.Sample(i)
+}
+
+func (f *Func) Iter() Iterator {
+	return &indexedIter{ds: f}
+}
+
+// Stream is a dataset streamed by the iterators that Open creates.
+type Stream struct {
+	Open func() Iterator
+}
+
+func NewStream(open func() Iterator) *Stream {
+	return &Stream{Open: open}
+}
+
+func (s *Stream) Iter() Iterator {
+	return s.Open()
+}
+
+// IterFunc is an Iterator made by a function returning the next sample,
+// for Stream.
+type IterFunc func() (Sample, error)
+
+func (f IterFunc) Next() (Sample, error) {
+	return f()
+}
+
+func (f IterFunc) Close() error {
+	return nil
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// maxIDXValues bounds the values of an IDX file, so a corrupt header does
+// not make ReadIDX allocate too much memory.
+const maxIDXValues = 1 << 28
+
+// IDX is the content of a file in the IDX format of MNIST, its dimensions
+// and its values in row-major order.
+type IDX struct {
+	Shape []int
+	Data  []float64
+}
+
+// ReadIDX reads an IDX file of any of its value types (unsigned and signed
+// bytes, shorts, ints, floats and doubles, all big-endian).
+func ReadIDX(r io.Reader) (*IDX, error) {
+	r = bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, errors.New("invalid idx magic number")
+	}
+	var size int
+	switch magic[2] {
+	case 0x08, 0x09:
+		size = 1
+	case 0x0B:
+		size = 2
+	case 0x0C, 0x0D:
+		size = 4
+	case 0x0E:
+		size = 8
+	default:
+		return nil, errors.New("unknown idx value type")
+	}
+	if magic[3] == 0 {
+		return nil, errors.New("invalid idx dimensions")
+	}
+
+	idx := &IDX{Shape: make([]int, magic[3])}
+	count := 1
+	for i := range idx.Shape {
+		var dim uint32
+		if err := binary.Read(r, binary.BigEndian, &dim); err != nil {
+			return nil, err
+		}
+		if dim > 0 && uint64(count) > maxIDXValues/uint64(dim) {
+			return nil, errors.New("idx dimensions too large")
+		}
+		idx.Shape[i] = int(dim)
+		count *= int(dim)
+	}
+
+	// The buffer grows with the read data, not with the header.
+	var data bytes.Buffer
+	if _, err := io.CopyN(&data, r, int64(count)*int64(size)); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("truncated idx file")
+		}
+		return nil, err
+	}
+	buf := data.Bytes()
+	idx.Data = make([]float64, count)
+	for i := range idx.Data {
+		b := buf[i*size : (i+1)*size]
+		switch magic[2] {
+		case 0x08:
+			idx.Data[i] = float64(b[0])
+		case 0x09:
+			idx.Data[i] = float64(int8(b[0]))
+		case 0x0B:
+			idx.Data[i] = float64(int16(binary.BigEndian.Uint16(b)))
+		case 0x0C:
+			idx.Data[i] = float64(int32(binary.BigEndian.Uint32(b)))
+		case 0x0D:
+			idx.Data[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case 0x0E:
+			idx.Data[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+	return idx, nil
+}
+
+// LoadIDX reads an IDX file, gzipped if its name ends in ".gz".
+func LoadIDX(path string) (*IDX, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ReadIDX(r)
+}
+
+// Images returns the images of an IDX file of shape [n, rows, cols] as
+// [cols, rows, 1] tensors, the [w, h, c] layout of Conv2D, with the pixels
+// scaled by 1/255.
+func (idx *IDX) Images() ([]tensor.Tensor, error) {
+	if len(idx.Shape) != 3 {
+		return nil, errors.New("the idx file has not images")
+	}
+	n, h, w := idx.Shape[0], idx.Shape[1], idx.Shape[2]
+	images := make([]tensor.Tensor, n)
+	for i := range images {
+		img := tensor.NewZeroTensor(w, h, 1)
+		pixels := idx.Data[i*w*h : (i+1)*w*h]
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Set(pixels[y*w+x]/255.0, x, y, 0)
+			}
+		}
+		images[i] = img
+	}
+	return images, nil
+}
+
+// Labels returns the labels of an IDX file of shape [n] as one-hot tensors
+// of the given number of classes.
+func (idx *IDX) Labels(classes int) ([]tensor.Tensor, error) {
+	if len(idx.Shape) != 1 {
+		return nil, errors.New("the idx file has not labels")
+	}
+	labels := make([]tensor.Tensor, len(idx.Data))
+	for i, v := range idx.Data {
+		label, err := oneHot(int(v), classes)
+		if err != nil {
+			return nil, err
+		}
+		labels[i] = label
+	}
+	return labels, nil
+}
+
+// LoadMNIST reads a pair of MNIST (or Fashion-MNIST) images and labels
+// files, such as "train-images-idx3-ubyte" and "train-labels-idx1-ubyte",
+// as a dataset of [28, 28, 1] images and one-hot targets of 10 classes.
+func LoadMNIST(images, labels string) (*Slice, error) {
+	idx, err := LoadIDX(images)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := idx.Images()
+	if err != nil {
+		return nil, err
+	}
+	idx, err = LoadIDX(labels)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := idx.Labels(10)
+	if err != nil {
+		return nil, err
+	}
+	return NewSlice(inputs, targets)
+}
+
+// oneHot returns a vector of classes zeros with a 1 at class.
+func oneHot(class, classes int) (tensor.Tensor, error) {
+	if class < 0 || class >= classes {
+		return nil, errors.New("label out of range")
+	}
+	t := tensor.NewZeroTensor(classes)
+	t.Set(1, class)
+	return t, nil
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// idxFixture returns an IDX file of the given value type and dimensions.
+func idxFixture(kind byte, dims []uint32, values []byte) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0, 0, kind, byte(len(dims))})
+	binary.Write(&b, binary.BigEndian, dims)
+	b.Write(values)
+	return b.Bytes()
+}
+
+func writeFixture(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkOneHot(t *testing.T, label tensor.Tensor, class, classes int) {
+	t.Helper()
+	if label.Size() != classes {
+		t.Fatalf("label size %d, want %d", label.Size(), classes)
+	}
+	for i, v := range label.GetData() {
+		if (i == class) != (v == 1) || v != 0 && v != 1 {
+			t.Fatalf("label %v, want class %d", label.GetData(), class)
+		}
+	}
+}
+
+func TestIDXImagesLayout(t *testing.T) {
+	// 2 images of 3 rows and 2 columns.
+	pixels := make([]byte, 12)
+	for i := range pixels {
+		pixels[i] = byte(i * 20)
+	}
+	idx, err := ReadIDX(bytes.NewReader(idxFixture(0x08, []uint32{2, 3, 2}, pixels)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := idx.Images()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("%d images, want 2", len(images))
+	}
+	for n, img := range images {
+		if !tensor.CompareShape(img.GetShape(), []int{2, 3, 1}) {
+			t.Fatalf("shape %v, want [2 3 1]", img.GetShape())
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 2; x++ {
+				v, _ := img.Get(x, y, 0)
+				want := float64(pixels[n*6+y*2+x]) / 255
+				if v != want {
+					t.Fatalf("image %d pixel (%d, %d) is %v, want %v", n, x, y, v, want)
+				}
+			}
+		}
+	}
+}
+
+func TestIDXValueTypes(t *testing.T) {
+	var values bytes.Buffer
+	binary.Write(&values, binary.BigEndian, []float32{1.5, -2})
+	idx, err := ReadIDX(bytes.NewReader(idxFixture(0x0D, []uint32{2}, values.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Data[0] != 1.5 || idx.Data[1] != -2 {
+		t.Fatalf("float values %v", idx.Data)
+	}
+
+	values.Reset()
+	binary.Write(&values, binary.BigEndian, []int16{-300, 7})
+	idx, err = ReadIDX(bytes.NewReader(idxFixture(0x0B, []uint32{2}, values.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Data[0] != -300 || idx.Data[1] != 7 {
+		t.Fatalf("short values %v", idx.Data)
+	}
+}
+
+func TestIDXInvalid(t *testing.T) {
+	cases := map[string][]byte{
+		"magic":     {1, 0, 8, 1, 0, 0, 0, 0},
+		"type":      {0, 0, 7, 1, 0, 0, 0, 0},
+		"huge":      idxFixture(0x08, []uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}, nil),
+		"truncated": idxFixture(0x08, []uint32{2, 28, 28}, make([]byte, 100)),
+	}
+	for name, content := range cases {
+		if _, err := ReadIDX(bytes.NewReader(content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadMNIST(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(idxFixture(0x08, []uint32{3, 28, 28}, make([]byte, 3*28*28)))
+	w.Close()
+	images := writeFixture(t, "images.gz", gz.Bytes())
+	labels := writeFixture(t, "labels", idxFixture(0x08, []uint32{3}, []byte{3, 0, 9}))
+
+	ds, err := LoadMNIST(images, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 3 {
+		t.Fatalf("%d samples, want 3", ds.Len())
+	}
+	for i, class := range []int{3, 0, 9} {
+		s, _ := ds.Get(i)
+		if !tensor.CompareShape(s.Input.GetShape(), []int{28, 28, 1}) {
+			t.Fatalf("shape %v, want [28 28 1]", s.Input.GetShape())
+		}
+		checkOneHot(t, s.Target, class, 10)
+	}
+
+	labels = writeFixture(t, "labels", idxFixture(0x08, []uint32{3}, []byte{3, 10, 9}))
+	if _, err := LoadMNIST(images, labels); err == nil {
+		t.Fatal("expected an error for a label out of range")
+	}
+}
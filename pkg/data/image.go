@@ -0,0 +1,273 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Interpolation is how Resize computes the values between the source
+// pixels.
+type Interpolation int
+
+const (
+	// Bilinear interpolates between the 2x2 nearest pixels.
+	Bilinear Interpolation = iota
+	// Nearest takes the nearest pixel.
+	Nearest
+	// Bicubic interpolates between the 4x4 nearest pixels with the
+	// Catmull-Rom spline, sharper than Bilinear.
+	Bicubic
+	// Area averages the pixels covered by every output pixel, the best
+	// choice to shrink images.
+	Area
+)
+
+// ColorMode is the number of channels of the images of an ImageFolder.
+type ColorMode int
+
+const (
+	// RGB gives 3 channels, red, green and blue.
+	RGB ColorMode = iota
+	// Gray gives 1 channel, the luminance.
+	Gray
+)
+
+// imageExts are the extensions of the files that ImageFolder decodes.
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// ImageFolder is an Indexed dataset of the images under Root, with a
+// subdirectory per class. The images are decoded when they are requested,
+// converted to Color and resized to Width x Height as [w, h, c] tensors
+// with their values in [0, 1], and their targets are one-hot vectors of
+// the classes, in the order of Classes. A Width or Height of 0 keeps the
+// size of the images.
+type ImageFolder struct {
+	Root    string
+	Classes []string
+	Paths   []string
+	Labels  []int
+
+	Width         int
+	Height        int
+	Interpolation Interpolation
+	Color         ColorMode
+}
+
+// NewImageFolder lists the JPEG, PNG and GIF files of the subdirectories
+// of root, which are the classes in alphabetical order.
+func NewImageFolder(root string, width, height int) (*ImageFolder, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	f := &ImageFolder{
+		Root:   root,
+		Width:  width,
+		Height: height,
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		label := len(f.Classes)
+		f.Classes = append(f.Classes, entry.Name())
+		dir := filepath.Join(root, entry.Name())
+		err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && imageExts[strings.ToLower(filepath.Ext(path))] {
+				f.Paths = append(f.Paths, path)
+				f.Labels = append(f.Labels, label)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(f.Classes) == 0 {
+		return nil, errors.New("no class directories in " + root)
+	}
+	return f, nil
+}
+
+func (f *ImageFolder) Len() int {
+	return len(f.Paths)
+}
+
+func (f *ImageFolder) Get(i int) (Sample, error) {
+	if i < 0 || i >= len(f.Paths) {
+		return Sample{}, errors.New("index out of range")
+	}
+	input, err := f.Load(f.Paths[i])
+	if err != nil {
+		return Sample{}, err
+	}
+	target, err := oneHot(f.Labels[i], len(f.Classes))
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{Input: input, Target: target}, nil
+}
+
+func (f *ImageFolder) Iter() Iterator {
+	return &indexedIter{ds: f}
+}
+
+// Load decodes an image file as the inputs of the dataset, to predict
+// new images the same way.
+func (f *ImageFolder) Load(path string) (tensor.Tensor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	t := imageTensor(img, f.Color)
+	if f.Width > 0 && f.Height > 0 {
+		t = Resize(t, f.Width, f.Height, f.Interpolation)
+		if f.Interpolation == Bicubic {
+			data := t.GetData()
+			for i, v := range data {
+				data[i] = math.Max(0, math.Min(1, v))
+			}
+		}
+	}
+	return t, nil
+}
+
+// imageTensor converts an image to a [w, h, c] tensor of 8 bit values
+// scaled by 1/255.
+func imageTensor(img image.Image, mode ColorMode) tensor.Tensor {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	channels := 3
+	if mode == Gray {
+		channels = 1
+	}
+	t := tensor.NewZeroTensor(w, h, channels)
+	data := t.GetData()
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			c := img.At(x+bounds.Min.X, y+bounds.Min.Y)
+			i := (x*h + y) * channels
+			if mode == Gray {
+				data[i] = float64(color.GrayModel.Convert(c).(color.Gray).Y) / 255.0
+				continue
+			}
+			rgb := color.NRGBAModel.Convert(c).(color.NRGBA)
+			data[i] = float64(rgb.R) / 255.0
+			data[i+1] = float64(rgb.G) / 255.0
+			data[i+2] = float64(rgb.B) / 255.0
+		}
+	}
+	return t
+}
+
+// tap is a source pixel and its weight in an output pixel.
+type tap struct {
+	index  int
+	weight float64
+}
+
+// taps returns the source pixels of every output pixel to resize an axis
+// of src pixels to dst ones.
+func taps(src, dst int, interp Interpolation) [][]tap {
+	scale := float64(src) / float64(dst)
+	clamp := func(i int) int {
+		if i < 0 {
+			return 0
+		}
+		if i >= src {
+			return src - 1
+		}
+		return i
+	}
+	out := make([][]tap, dst)
+	for i := range out {
+		// The position of the center of the output pixel in the source.
+		center := (float64(i)+0.5)*scale - 0.5
+		switch interp {
+		case Nearest:
+			out[i] = []tap{{clamp(int(math.Floor(center + 0.5))), 1}}
+		case Bicubic:
+			base := math.Floor(center)
+			t := center - base
+			for k := -1; k <= 2; k++ {
+				out[i] = append(out[i], tap{clamp(int(base) + k), cubic(float64(k) - t)})
+			}
+		case Area:
+			start, end := float64(i)*scale, float64(i+1)*scale
+			for j := int(start); float64(j) < end && j < src; j++ {
+				overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+				if overlap > 0 {
+					out[i] = append(out[i], tap{j, overlap / scale})
+				}
+			}
+		default:
+			center = math.Max(0, math.Min(float64(src-1), center))
+			base := math.Floor(center)
+			t := center - base
+			out[i] = []tap{{int(base), 1 - t}, {clamp(int(base) + 1), t}}
+		}
+	}
+	return out
+}
+
+// cubic is the Catmull-Rom kernel.
+func cubic(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return 1.5*x*x*x - 2.5*x*x + 1
+	}
+	if x < 2 {
+		return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+	}
+	return 0
+}
+
+// Resize returns a [w, h, c] tensor resized to width x height with the
+// given interpolation.
+func Resize(t tensor.Tensor, width, height int, interp Interpolation) tensor.Tensor {
+	w, h, c := t.ShapeAt(0), t.ShapeAt(1), t.ShapeAt(2)
+	xTaps := taps(w, width, interp)
+	yTaps := taps(h, height, interp)
+	in := t.GetData()
+	out := tensor.NewZeroTensor(width, height, c)
+	data := out.GetData()
+	for x, xt := range xTaps {
+		for y, yt := range yTaps {
+			i := (x*height + y) * c
+			for _, tx := range xt {
+				for _, ty := range yt {
+					weight := tx.weight * ty.weight
+					j := (tx.index*h + ty.index) * c
+					for k := 0; k < c; k++ {
+						data[i+k] += weight * in[j+k]
+					}
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// pixels is a 3x2 image with a different color at every pixel.
+var pixels = [3][2]color.NRGBA{
+	{{255, 0, 0, 255}, {0, 255, 0, 255}},
+	{{0, 0, 255, 255}, {255, 255, 255, 255}},
+	{{0, 0, 0, 255}, {51, 51, 51, 255}},
+}
+
+func pixelsImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for x := range pixels {
+		for y, c := range pixels[x] {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// writeImage encodes img as a PNG or GIF file, by the extension of path.
+func writeImage(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if filepath.Ext(path) == ".gif" {
+		// a palette of the exact colors, without dithering
+		var palette color.Palette
+		for x := range pixels {
+			for _, c := range pixels[x] {
+				palette = append(palette, c)
+			}
+		}
+		paletted := image.NewPaletted(img.Bounds(), palette)
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+		err = gif.Encode(file, paletted, nil)
+	} else {
+		err = png.Encode(file, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkPixels(t *testing.T, got tensor.Tensor, mode ColorMode) {
+	t.Helper()
+	channels := 3
+	if mode == Gray {
+		channels = 1
+	}
+	if !reflect.DeepEqual(got.GetShape(), []int{3, 2, channels}) {
+		t.Fatalf("shape %v, want [3 2 %d]", got.GetShape(), channels)
+	}
+	data := got.GetData()
+	for x := range pixels {
+		for y, c := range pixels[x] {
+			i := (x*2 + y) * channels
+			want := []float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+			if mode == Gray {
+				want = []float64{float64(color.GrayModel.Convert(c).(color.Gray).Y) / 255}
+			}
+			checkValues(t, "pixel", data[i:i+channels], want)
+		}
+	}
+}
+
+func TestImageFolder(t *testing.T) {
+	root := t.TempDir()
+	writeImage(t, filepath.Join(root, "dog", "a.png"), pixelsImage())
+	writeImage(t, filepath.Join(root, "dog", "nested", "b.PNG"), pixelsImage())
+	writeImage(t, filepath.Join(root, "cat", "c.gif"), pixelsImage())
+	writeImage(t, filepath.Join(root, "top.png"), pixelsImage())
+	if err := os.WriteFile(filepath.Join(root, "cat", "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "bird"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewImageFolder(root, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Classes, []string{"bird", "cat", "dog"}) {
+		t.Fatalf("classes %v", f.Classes)
+	}
+	if f.Len() != 3 || !reflect.DeepEqual(f.Labels, []int{1, 2, 2}) {
+		t.Fatalf("paths %v with labels %v", f.Paths, f.Labels)
+	}
+	for i, label := range f.Labels {
+		s, err := f.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkOneHot(t, s.Target, label, 3)
+		checkPixels(t, s.Input, RGB)
+	}
+	if _, err := f.Get(3); err == nil {
+		t.Error("expected an error out of range")
+	}
+
+	f.Color = Gray
+	for i := range f.Paths {
+		s, err := f.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, s.Input, Gray)
+	}
+
+	f.Width, f.Height, f.Interpolation = 6, 4, Nearest
+	input, err := f.Load(f.Paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input.GetShape(), []int{6, 4, 1}) {
+		t.Fatalf("resized shape %v", input.GetShape())
+	}
+
+	if _, err := NewImageFolder(filepath.Join(root, "bird"), 0, 0); err == nil {
+		t.Error("expected an error without class directories")
+	}
+}
+
+func TestResize(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     []float64
+		width  int
+		interp Interpolation
+		want   []float64
+	}{
+		{"nearest down", []float64{0, 1, 2, 3}, 2, Nearest, []float64{1, 3}},
+		{"bilinear down", []float64{0, 1, 2, 3}, 2, Bilinear, []float64{0.5, 2.5}},
+		{"area down", []float64{0, 1, 2, 3}, 2, Area, []float64{0.5, 2.5}},
+		{"area fraction", []float64{0, 3, 6}, 2, Area, []float64{1, 5}},
+		{"nearest up", []float64{0, 1}, 4, Nearest, []float64{0, 0, 1, 1}},
+		{"bilinear up", []float64{0, 1}, 4, Bilinear, []float64{0, 0.25, 0.75, 1}},
+		{"area up", []float64{0, 1}, 4, Area, []float64{0, 0, 1, 1}},
+	}
+	for _, c := range cases {
+		// the values along the width, then along the height
+		for _, transpose := range []bool{false, true} {
+			shape, size := []int{len(c.in), 1, 1}, []int{c.width, 1}
+			if transpose {
+				shape, size = []int{1, len(c.in), 1}, []int{1, c.width}
+			}
+			got := Resize(tensor.NewTensor(append([]float64{}, c.in...), shape...), size[0], size[1], c.interp)
+			checkValues(t, c.name, got.GetData(), c.want)
+		}
+	}
+
+	// the same size keeps the image with every interpolation
+	img := imageTensor(pixelsImage(), RGB)
+	for _, interp := range []Interpolation{Nearest, Bilinear, Bicubic, Area} {
+		got := Resize(img, 3, 2, interp)
+		if !reflect.DeepEqual(got.GetShape(), img.GetShape()) {
+			t.Fatalf("shape %v", got.GetShape())
+		}
+		checkValues(t, "same size", got.GetData(), img.GetData())
+	}
+}
+
+func TestTaps(t *testing.T) {
+	for _, interp := range []Interpolation{Nearest, Bilinear, Bicubic, Area} {
+		for _, sizes := range [][2]int{{4, 2}, {3, 2}, {2, 5}, {7, 3}, {1, 4}} {
+			for i, ts := range taps(sizes[0], sizes[1], interp) {
+				sum := 0.0
+				for _, tp := range ts {
+					if tp.index < 0 || tp.index >= sizes[0] {
+						t.Fatalf("%v %v: pixel %d reads %d", interp, sizes, i, tp.index)
+					}
+					sum += tp.weight
+				}
+				if math.Abs(sum-1) > 1e-12 {
+					t.Fatalf("%v %v: the weights of pixel %d sum %v", interp, sizes, i, sum)
+				}
+			}
+		}
+	}
+}
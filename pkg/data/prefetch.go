@@ -0,0 +1,92 @@
+package data
+
+import (
+	"io"
+	"sync"
+)
+
+// prefetched reads the samples of a dataset in the background.
+type prefetched struct {
+	ds   Dataset
+	size int
+}
+
+// Prefetch returns a dataset whose iterators read up to size samples of ds
+// ahead in a goroutine, so the next samples are loaded while the model is
+// trained with the current one.
+func Prefetch(ds Dataset, size int) Dataset {
+	if size < 1 {
+		size = 1
+	}
+	return &prefetched{ds, size}
+}
+
+func (p *prefetched) Len() int {
+	return Len(p.ds)
+}
+
+func (p *prefetched) Iter() Iterator {
+	it := &prefetchIter{
+		results:  make(chan result, p.size),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go it.read(p.ds.Iter())
+	return it
+}
+
+type result struct {
+	sample Sample
+	err    error
+}
+
+type prefetchIter struct {
+	results  chan result
+	done     chan struct{}
+	finished chan struct{}
+	closing  sync.Once
+	closeErr error
+	err      error
+}
+
+// read sends the samples of source until it fails or ends, or the iterator
+// is closed.
+func (it *prefetchIter) read(source Iterator) {
+	defer close(it.finished)
+	defer close(it.results)
+	for {
+		s, err := source.Next()
+		select {
+		case it.results <- result{s, err}:
+		case <-it.done:
+			it.closeErr = source.Close()
+			return
+		}
+		if err != nil {
+			it.closeErr = source.Close()
+			return
+		}
+	}
+}
+
+func (it *prefetchIter) Next() (Sample, error) {
+	if it.err != nil {
+		return Sample{}, it.err
+	}
+	r, ok := <-it.results
+	if !ok {
+		return Sample{}, io.EOF
+	}
+	if r.err != nil {
+		it.err = r.err
+	}
+	return r.sample, r.err
+}
+
+func (it *prefetchIter) Close() error {
+	it.closing.Do(func() {
+		close(it.done)
+		<-it.finished
+	})
+	return it.closeErr
+}
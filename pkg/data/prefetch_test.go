@@ -0,0 +1,112 @@
+package data
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// trackedIter counts the samples read from an iterator and whether it was
+// closed, from any goroutine.
+type trackedIter struct {
+	Iterator
+	mu     sync.Mutex
+	read   int
+	closed int
+}
+
+func (it *trackedIter) Next() (Sample, error) {
+	s, err := it.Iterator.Next()
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if err == nil {
+		it.read++
+	}
+	return s, err
+}
+
+func (it *trackedIter) Close() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.closed++
+	return it.Iterator.Close()
+}
+
+func TestPrefetch(t *testing.T) {
+	ds := Prefetch(Map(numbers(5), func(s Sample) (Sample, error) {
+		return s, nil
+	}), 2)
+	for pass := 0; pass < 2; pass++ {
+		if got := values(t, ds); !reflect.DeepEqual(got, scalars(0, 1, 2, 3, 4)) {
+			t.Fatalf("pass %d: %v", pass, got)
+		}
+	}
+	if Len(ds) != 5 {
+		t.Fatalf("Len is %d, want 5", Len(ds))
+	}
+}
+
+func TestPrefetchCloseEarly(t *testing.T) {
+	source := &trackedIter{Iterator: numbers(1000).Iter()}
+	it := Prefetch(NewStream(func() Iterator { return source }), 4).Iter()
+	for i := 0; i < 3; i++ {
+		s, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Input.GetData()[0] != float64(i) {
+			t.Fatalf("sample %d is %v", i, s.Input.GetData())
+		}
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.closed != 1 {
+		t.Fatalf("the source was closed %d times, want 1", source.closed)
+	}
+	// the reader stops after filling the buffer
+	if source.read > 3+4+1 {
+		t.Fatalf("%d samples were read ahead", source.read)
+	}
+}
+
+func TestPrefetchError(t *testing.T) {
+	fail := errors.New("fail")
+	n := 0
+	ds := Prefetch(NewStream(func() Iterator {
+		return IterFunc(func() (Sample, error) {
+			n++
+			if n == 3 {
+				return Sample{}, fail
+			}
+			return numbers(1).Get(0)
+		})
+	}), 2)
+	it := ds.Iter()
+	defer it.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := it.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := it.Next(); err != fail {
+			t.Fatalf("got %v, want the error of the source", err)
+		}
+	}
+
+	empty := Prefetch(numbers(0), 1).Iter()
+	if _, err := empty.Next(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	if err := empty.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
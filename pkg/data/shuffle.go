@@ -0,0 +1,117 @@
+package data
+
+import (
+	"errors"
+	"io"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+)
+
+// shuffled gives the samples of a dataset in a new random order in every
+// iteration.
+type shuffled struct {
+	ds     Dataset
+	buffer int
+	rand   random.Source
+}
+
+// Shuffle returns a dataset with the samples of ds in a random order, a new
+// one in every iteration. With a buffer of n samples, every sample is drawn
+// at random from the next n of ds, which works with streamed datasets.
+// Without a buffer ds must be Indexed and all its samples are shuffled. The
+// order is drawn from src or, if it is nil, from the shared source of
+// package random.
+func Shuffle(ds Dataset, buffer int, src random.Source) Dataset {
+	return &shuffled{ds, buffer, src}
+}
+
+func (s *shuffled) Len() int {
+	return Len(s.ds)
+}
+
+func (s *shuffled) Iter() Iterator {
+	src := random.Or(s.rand)
+	if s.buffer > 0 {
+		return &bufferIter{it: s.ds.Iter(), size: s.buffer, rand: src}
+	}
+	indexed, ok := s.ds.(Indexed)
+	if !ok {
+		return &errorIter{errors.New("shuffling without buffer needs an indexed dataset")}
+	}
+	order := make([]int, indexed.Len())
+	for i := range order {
+		order[i] = i
+	}
+	src.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	return &orderIter{ds: indexed, order: order}
+}
+
+// orderIter iterates an Indexed dataset in the given order.
+type orderIter struct {
+	ds    Indexed
+	order []int
+	index int
+}
+
+func (it *orderIter) Next() (Sample, error) {
+	if it.index >= len(it.order) {
+		return Sample{}, io.EOF
+	}
+	it.index++
+	return it.ds.Get(it.order[it.index-1])
+}
+
+func (it *orderIter) Close() error {
+	return nil
+}
+
+// bufferIter draws every sample at random from a buffer refilled from it.
+type bufferIter struct {
+	it     Iterator
+	size   int
+	rand   random.Source
+	buffer []Sample
+	ended  bool
+}
+
+func (it *bufferIter) Next() (Sample, error) {
+	for !it.ended && len(it.buffer) < it.size {
+		s, err := it.it.Next()
+		if err == io.EOF {
+			it.ended = true
+			break
+		}
+		if err != nil {
+			return Sample{}, err
+		}
+		it.buffer = append(it.buffer, s)
+	}
+	if len(it.buffer) == 0 {
+		return Sample{}, io.EOF
+	}
+	i := it.rand.Intn(len(it.buffer))
+	s := it.buffer[i]
+	last := len(it.buffer) - 1
+	it.buffer[i] = it.buffer[last]
+	it.buffer = it.buffer[:last]
+	return s, nil
+}
+
+func (it *bufferIter) Close() error {
+	return it.it.Close()
+}
+
+// errorIter fails at the first sample.
+type errorIter struct {
+	err error
+}
+
+func (it *errorIter) Next() (Sample, error) {
+	return Sample{}, it.err
+}
+
+func (it *errorIter) Close() error {
+	return nil
+}
@@ -0,0 +1,353 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Scaling selects how a numeric column is scaled.
+type Scaling int
+
+const (
+	// ScaleNone keeps the values.
+	ScaleNone Scaling = iota
+	// Standardize subtracts the mean and divides by the standard deviation.
+	Standardize
+	// MinMax maps the minimum to 0 and the maximum to 1.
+	MinMax
+)
+
+// Encoding selects how a categorical column is encoded.
+type Encoding int
+
+const (
+	// OneHot gives a value per category, 1 for the category of the row and
+	// 0 for the others. Unknown categories are all 0.
+	OneHot Encoding = iota
+	// Ordinal gives the index of the category, -1 for unknown categories.
+	Ordinal
+)
+
+// Missing selects what is done with the missing values of a column.
+type Missing int
+
+const (
+	// MissingFill uses the mean of a numeric column or the most frequent
+	// category of a categorical one.
+	MissingFill Missing = iota
+	// MissingConstant uses the Fill of the column.
+	MissingConstant
+	// MissingDrop drops the rows with the value missing.
+	MissingDrop
+	// MissingError fails.
+	MissingError
+)
+
+// Column describes how a column of a table becomes values of a tensor.
+type Column struct {
+	Name        string
+	Categorical bool
+	Scaling     Scaling
+	Encoding    Encoding
+	Missing     Missing
+	Fill        string
+}
+
+func NewNumeric(name string, scaling Scaling) Column {
+	return Column{
+		Name:    name,
+		Scaling: scaling,
+	}
+}
+
+func NewCategorical(name string, encoding Encoding) Column {
+	return Column{
+		Name:        name,
+		Categorical: true,
+		Encoding:    encoding,
+	}
+}
+
+// ColumnStats are the statistics of a column fitted by a Preprocessor.
+type ColumnStats struct {
+	Mean       float64
+	Std        float64
+	Min        float64
+	Max        float64
+	Fill       string
+	Categories []string
+}
+
+// Preprocessor turns the rows of a table into input and target tensors,
+// with the columns selected by Inputs and Targets in order. Fit takes the
+// statistics of the columns from a table, and they are saved with the
+// preprocessor to apply the same transformation at inference time. The
+// values in MissingValues, compared without surrounding spaces, are
+// missing.
+type Preprocessor struct {
+	Inputs        []Column
+	Targets       []Column
+	MissingValues []string
+	Stats         map[string]*ColumnStats
+}
+
+func NewPreprocessor(inputs, targets []Column) *Preprocessor {
+	return &Preprocessor{
+		Inputs:        inputs,
+		Targets:       targets,
+		MissingValues: []string{"", "NA", "NaN", "?", "null"},
+	}
+}
+
+func (p *Preprocessor) missing(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, m := range p.MissingValues {
+		if value == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Fit computes the statistics of the columns from the values of a table
+// that are not missing.
+func (p *Preprocessor) Fit(t *Table) error {
+	p.Stats = map[string]*ColumnStats{}
+	for _, c := range append(append([]Column{}, p.Inputs...), p.Targets...) {
+		values, err := t.Column(c.Name)
+		if err != nil {
+			return err
+		}
+		var present []string
+		for _, v := range values {
+			if !p.missing(v) {
+				present = append(present, strings.TrimSpace(v))
+			}
+		}
+		if c.Categorical {
+			p.Stats[c.Name] = fitCategorical(present)
+			continue
+		}
+		stats, err := fitNumeric(c.Name, present)
+		if err != nil {
+			return err
+		}
+		p.Stats[c.Name] = stats
+	}
+	return nil
+}
+
+func fitCategorical(values []string) *ColumnStats {
+	counts := map[string]int{}
+	for _, v := range values {
+		counts[v]++
+	}
+	stats := &ColumnStats{}
+	for v := range counts {
+		stats.Categories = append(stats.Categories, v)
+	}
+	sort.Strings(stats.Categories)
+	best := 0
+	for _, v := range stats.Categories {
+		if counts[v] > best {
+			best = counts[v]
+			stats.Fill = v
+		}
+	}
+	return stats
+}
+
+func fitNumeric(name string, values []string) (*ColumnStats, error) {
+	stats := &ColumnStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	if len(values) == 0 {
+		return nil, errors.New("column " + name + " has no values")
+	}
+	sum, square := 0.0, 0.0
+	for _, v := range values {
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("column " + name + " has a not numeric value " + v)
+		}
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return nil, errors.New("column " + name + " has a not finite value " + v)
+		}
+		sum += x
+		square += x * x
+		stats.Min = math.Min(stats.Min, x)
+		stats.Max = math.Max(stats.Max, x)
+	}
+	n := float64(len(values))
+	stats.Mean = sum / n
+	stats.Std = math.Sqrt(math.Max(square/n-stats.Mean*stats.Mean, 0))
+	if math.IsInf(stats.Mean, 0) || math.IsInf(stats.Std, 0) {
+		return nil, errors.New("column " + name + " has too large values")
+	}
+	stats.Fill = strconv.FormatFloat(stats.Mean, 'g', -1, 64)
+	return stats, nil
+}
+
+// Width returns the number of values the column gives to a tensor.
+func (p *Preprocessor) Width(c Column) int {
+	if c.Categorical && c.Encoding == OneHot {
+		if stats := p.Stats[c.Name]; stats != nil {
+			return len(stats.Categories)
+		}
+		return 0
+	}
+	return 1
+}
+
+// encode appends the values of a column to out. It returns false if the
+// row must be dropped.
+func (p *Preprocessor) encode(c Column, value string, out []float64) ([]float64, bool, error) {
+	stats := p.Stats[c.Name]
+	if stats == nil {
+		return nil, false, errors.New("the preprocessor is not fitted for column " + c.Name)
+	}
+	if p.missing(value) {
+		switch c.Missing {
+		case MissingFill:
+			value = stats.Fill
+		case MissingConstant:
+			value = c.Fill
+		case MissingDrop:
+			return out, false, nil
+		default:
+			return nil, false, errors.New("missing value in column " + c.Name)
+		}
+	}
+	value = strings.TrimSpace(value)
+	if c.Categorical {
+		index := sort.SearchStrings(stats.Categories, value)
+		if index >= len(stats.Categories) || stats.Categories[index] != value {
+			index = -1
+		}
+		if c.Encoding == Ordinal {
+			return append(out, float64(index)), true, nil
+		}
+		for i := range stats.Categories {
+			if i == index {
+				out = append(out, 1)
+			} else {
+				out = append(out, 0)
+			}
+		}
+		return out, true, nil
+	}
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, false, errors.New("column " + c.Name + " has a not numeric value " + value)
+	}
+	switch c.Scaling {
+	case Standardize:
+		if stats.Std > 0 {
+			x = (x - stats.Mean) / stats.Std
+		} else {
+			x -= stats.Mean
+		}
+	case MinMax:
+		if stats.Max > stats.Min {
+			x = (x - stats.Min) / (stats.Max - stats.Min)
+		} else {
+			x -= stats.Min
+		}
+	}
+	return append(out, x), true, nil
+}
+
+// row makes the tensor of the given columns of a row, or nil if the row
+// must be dropped.
+func (p *Preprocessor) row(t *Table, row []string, columns []Column) (tensor.Tensor, error) {
+	var out []float64
+	for _, c := range columns {
+		i := t.Index(c.Name)
+		if i < 0 {
+			return nil, errors.New("unknown column " + c.Name)
+		}
+		if i >= len(row) {
+			return nil, errors.New("short row")
+		}
+		var keep bool
+		var err error
+		out, keep, err = p.encode(c, row[i], out)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			return nil, nil
+		}
+	}
+	return tensor.NewTensor(out, len(out)), nil
+}
+
+// Transform returns the inputs and targets of the rows of a table. The rows
+// with a missing value in a MissingDrop column are dropped.
+func (p *Preprocessor) Transform(t *Table) (*Slice, error) {
+	s := &Slice{}
+	for _, row := range t.Rows {
+		input, err := p.row(t, row, p.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		target, err := p.row(t, row, p.Targets)
+		if err != nil {
+			return nil, err
+		}
+		if input == nil || target == nil {
+			continue
+		}
+		s.Inputs = append(s.Inputs, input)
+		s.Targets = append(s.Targets, target)
+	}
+	return s, nil
+}
+
+// TransformInputs returns the inputs of the rows of a table, which can
+// lack the target columns, for inference. Every row gives an input, so a
+// missing value in a MissingDrop column is an error instead of dropping
+// the row.
+func (p *Preprocessor) TransformInputs(t *Table) ([]tensor.Tensor, error) {
+	inputs := make([]tensor.Tensor, len(t.Rows))
+	for r, row := range t.Rows {
+		input, err := p.row(t, row, p.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		if input == nil {
+			return nil, errors.New("row " + strconv.Itoa(r) + " has a missing value in a dropped column")
+		}
+		inputs[r] = input
+	}
+	return inputs, nil
+}
+
+// Save writes the preprocessor with its statistics as JSON.
+func (p *Preprocessor) Save(path string) error {
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+func LoadPreprocessor(path string) (*Preprocessor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p := &Preprocessor{}
+	err = json.NewDecoder(file).Decode(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
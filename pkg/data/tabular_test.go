@@ -0,0 +1,148 @@
+package data
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readTable(t *testing.T, content string) *Table {
+	t.Helper()
+	table, err := ReadCSV(strings.NewReader(content), true, ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func checkValues(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %v, want %v", name, got, want)
+	}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("%s: %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPreprocessorRoundTrip(t *testing.T) {
+	train := readTable(t, `age,city,score,k,label
+20,madrid,1,7,yes
+30,paris,NA,7,no
+?,madrid,3,7,yes
+40,rome,5,7,no
+`)
+	score := NewNumeric("score", MinMax)
+	score.Missing = MissingConstant
+	score.Fill = "0"
+	p := NewPreprocessor([]Column{
+		NewNumeric("age", Standardize),
+		NewCategorical("city", OneHot),
+		score,
+		NewNumeric("k", Standardize),
+	}, []Column{NewCategorical("label", Ordinal)})
+	if err := p.Fit(train); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "preprocessor.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPreprocessor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, p) {
+		t.Fatalf("loaded %+v, want %+v", loaded, p)
+	}
+
+	// Inference rows lack the target, have an unknown category and
+	// missing values.
+	inputs, err := loaded.TransformInputs(readTable(t, `age,city,score,k
+40,oslo,5,7
+NA,paris,?,9
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := math.Sqrt(200.0 / 3)
+	checkValues(t, "row 0", inputs[0].GetData(), []float64{10 / std, 0, 0, 0, 1, 0})
+	checkValues(t, "row 1", inputs[1].GetData(), []float64{0, 0, 1, 0, -0.25, 2})
+
+	ds, err := loaded.Transform(train)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 4 {
+		t.Fatalf("%d samples, want 4", ds.Len())
+	}
+	for i, label := range []float64{1, 0, 1, 0} {
+		checkValues(t, "label", ds.Targets[i].GetData(), []float64{label})
+	}
+	checkValues(t, "train row 2", ds.Inputs[2].GetData(), []float64{0, 1, 0, 0, 0.5, 0})
+}
+
+func TestPreprocessorMissing(t *testing.T) {
+	table := readTable(t, `x,c
+1,a
+NA,b
+3,
+`)
+	dropX := NewNumeric("x", ScaleNone)
+	dropX.Missing = MissingDrop
+	p := NewPreprocessor([]Column{dropX}, []Column{NewCategorical("c", OneHot)})
+	if err := p.Fit(table); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := p.Transform(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the missing x is dropped, the missing c filled with the most frequent
+	// category, the first one on a tie
+	if ds.Len() != 2 {
+		t.Fatalf("%d samples, want 2", ds.Len())
+	}
+	checkValues(t, "row 0", ds.Inputs[1].GetData(), []float64{3})
+	checkValues(t, "filled", ds.Targets[1].GetData(), []float64{1, 0})
+	if _, err := p.TransformInputs(table); err == nil {
+		t.Error("expected an error for a missing value in a dropped column")
+	}
+
+	failX := NewNumeric("x", ScaleNone)
+	failX.Missing = MissingError
+	p = NewPreprocessor([]Column{failX}, nil)
+	if err := p.Fit(table); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.TransformInputs(table); err == nil {
+		t.Error("expected an error for a missing value")
+	}
+}
+
+func TestPreprocessorInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not numeric": "x\n1\nabc\n",
+		"infinite":    "x\n1\nInf\n",
+		"overflow":    "x\n1e300\n-1e300\n",
+		"no values":   "x\nNA\n",
+	}
+	for name, content := range cases {
+		p := NewPreprocessor([]Column{NewNumeric("x", Standardize)}, nil)
+		if err := p.Fit(readTable(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	p := NewPreprocessor([]Column{NewNumeric("x", Standardize)}, nil)
+	if _, err := p.TransformInputs(readTable(t, "x\n1\n")); err == nil {
+		t.Error("expected an error without fitting")
+	}
+	if err := p.Fit(readTable(t, "y\n1\n")); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"errors"
+	"io"
+)
+
+// mapped applies a function to the samples of a dataset.
+type mapped struct {
+	ds Dataset
+	f  func(Sample) (Sample, error)
+}
+
+// Map returns a dataset with the samples of ds changed by f. It is Indexed
+// if ds is Indexed. f must copy the tensors of a sample before changing
+// them if ds keeps them in memory, like a Slice.
+func Map(ds Dataset, f func(Sample) (Sample, error)) Dataset {
+	if indexed, ok := ds.(Indexed); ok {
+		return &mappedIndexed{mapped{ds, f}, indexed}
+	}
+	return &mapped{ds, f}
+}
+
+func (m *mapped) Len() int {
+	return Len(m.ds)
+}
+
+func (m *mapped) Iter() Iterator {
+	return &mappedIter{m.ds.Iter(), m.f}
+}
+
+type mappedIndexed struct {
+	mapped
+	indexed Indexed
+}
+
+func (m *mappedIndexed) Len() int {
+	return m.indexed.Len()
+}
+
+func (m *mappedIndexed) Get(i int) (Sample, error) {
+	s, err := m.indexed.Get(i)
+	if err != nil {
+		return Sample{}, err
+	}
+	return m.f(s)
+}
+
+type mappedIter struct {
+	Iterator
+	f func(Sample) (Sample, error)
+}
+
+func (it *mappedIter) Next() (Sample, error) {
+	s, err := it.Iterator.Next()
+	if err != nil {
+		return Sample{}, err
+	}
+	return it.f(s)
+}
+
+// filtered keeps the samples of a dataset accepted by a function.
+type filtered struct {
+	ds   Dataset
+	keep func(Sample) bool
+}
+
+// Filter returns a dataset with the samples of ds for which keep is true.
+func Filter(ds Dataset, keep func(Sample) bool) Dataset {
+	return &filtered{ds, keep}
+}
+
+func (f *filtered) Iter() Iterator {
+	return &filteredIter{f.ds.Iter(), f.keep}
+}
+
+type filteredIter struct {
+	Iterator
+	keep func(Sample) bool
+}
+
+func (it *filteredIter) Next() (Sample, error) {
+	for {
+		s, err := it.Iterator.Next()
+		if err != nil || it.keep(s) {
+			return s, err
+		}
+	}
+}
+
+// window gives the samples of a dataset from Skip, at most Take of them if
+// Take is not negative.
+type window struct {
+	ds   Dataset
+	skip int
+	take int
+}
+
+// Take returns a dataset with the first n samples of ds.
+func Take(ds Dataset, n int) Dataset {
+	return newWindow(ds, 0, n)
+}
+
+// Skip returns a dataset with the samples of ds after the first n.
+func Skip(ds Dataset, n int) Dataset {
+	return newWindow(ds, n, -1)
+}
+
+func newWindow(ds Dataset, skip, take int) Dataset {
+	if w, ok := ds.(*window); ok {
+		// merge the windows of a window
+		if w.take >= 0 {
+			if skip >= w.take {
+				take = 0
+			} else if take < 0 || take > w.take-skip {
+				take = w.take - skip
+			}
+		}
+		skip += w.skip
+		ds = w.ds
+	}
+	if indexed, ok := ds.(Indexed); ok {
+		return &windowIndexed{window{ds, skip, take}, indexed}
+	}
+	return &window{ds, skip, take}
+}
+
+func (w *window) Len() int {
+	n := Len(w.ds)
+	if n < 0 {
+		return -1
+	}
+	n -= w.skip
+	if n < 0 {
+		n = 0
+	}
+	if w.take >= 0 && n > w.take {
+		n = w.take
+	}
+	return n
+}
+
+func (w *window) Iter() Iterator {
+	return &windowIter{Iterator: w.ds.Iter(), skip: w.skip, take: w.take}
+}
+
+type windowIndexed struct {
+	window
+	indexed Indexed
+}
+
+func (w *windowIndexed) Get(i int) (Sample, error) {
+	if i < 0 || i >= w.Len() {
+		return Sample{}, errors.New("index out of range")
+	}
+	return w.indexed.Get(w.skip + i)
+}
+
+func (w *windowIndexed) Iter() Iterator {
+	return &indexedIter{ds: w}
+}
+
+type windowIter struct {
+	Iterator
+	skip int
+	take int
+}
+
+func (it *windowIter) Next() (Sample, error) {
+	for ; it.skip > 0; it.skip-- {
+		_, err := it.Iterator.Next()
+		if err != nil {
+			return Sample{}, err
+		}
+	}
+	if it.take == 0 {
+		return Sample{}, io.EOF
+	}
+	if it.take > 0 {
+		it.take--
+	}
+	return it.Iterator.Next()
+}
+
+// repeated gives the samples of a dataset several times.
+type repeated struct {
+	ds    Dataset
+	count int
+}
+
+// Repeat returns a dataset with the samples of ds count times, or forever
+// if count is not positive.
+func Repeat(ds Dataset, count int) Dataset {
+	return &repeated{ds, count}
+}
+
+func (r *repeated) Iter() Iterator {
+	return &repeatedIter{ds: r.ds, left: r.count, it: r.ds.Iter()}
+}
+
+type repeatedIter struct {
+	ds    Dataset
+	left  int
+	it    Iterator
+	empty bool
+	done  bool
+}
+
+func (it *repeatedIter) Next() (Sample, error) {
+	if it.done {
+		return Sample{}, io.EOF
+	}
+	for {
+		s, err := it.it.Next()
+		if err != io.EOF {
+			it.empty = false
+			return s, err
+		}
+		if it.left > 0 {
+			it.left--
+			if it.left == 0 {
+				it.done = true
+				return Sample{}, io.EOF
+			}
+		}
+		if it.empty {
+			// the dataset has no samples, repeating it never ends
+			it.done = true
+			return Sample{}, io.EOF
+		}
+		err = it.it.Close()
+		if err != nil {
+			return Sample{}, err
+		}
+		it.it = it.ds.Iter()
+		it.empty = true
+	}
+}
+
+func (it *repeatedIter) Close() error {
+	return it.it.Close()
+}
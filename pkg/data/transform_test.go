@@ -0,0 +1,197 @@
+package data
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// numbers returns a dataset of n samples whose input and target are [i].
+func numbers(n int) *Slice {
+	inputs := make([]tensor.Tensor, n)
+	targets := make([]tensor.Tensor, n)
+	for i := range inputs {
+		inputs[i] = tensor.NewTensor([]float64{float64(i)}, 1)
+		targets[i] = tensor.NewTensor([]float64{float64(i)}, 1)
+	}
+	ds, _ := NewSlice(inputs, targets)
+	return ds
+}
+
+// streamed returns ds as a streamed dataset, counting its iterators.
+func streamed(ds Dataset, opened *int) Dataset {
+	return NewStream(func() Iterator {
+		*opened++
+		return ds.Iter()
+	})
+}
+
+// values reads the inputs of every sample of ds, and checks that Next keeps
+// returning io.EOF after the last one.
+func values(t *testing.T, ds Dataset) [][]float64 {
+	t.Helper()
+	it := ds.Iter()
+	defer it.Close()
+	out := [][]float64{}
+	for {
+		s, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s.Input.GetData())
+		if len(out) > 100 {
+			t.Fatal("the iterator does not end")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := it.Next(); err != io.EOF {
+			t.Fatalf("Next after io.EOF returned %v", err)
+		}
+	}
+	return out
+}
+
+func scalars(vs ...float64) [][]float64 {
+	out := make([][]float64, len(vs))
+	for i, v := range vs {
+		out[i] = []float64{v}
+	}
+	return out
+}
+
+func TestTransforms(t *testing.T) {
+	var opened int
+	even := func(s Sample) bool {
+		return int(s.Input.GetData()[0])%2 == 0
+	}
+	double := func(s Sample) (Sample, error) {
+		input := s.Input.Copy()
+		input.MulNumber(2)
+		return Sample{Input: input, Target: s.Target}, nil
+	}
+	cases := []struct {
+		name string
+		ds   Dataset
+		want [][]float64
+	}{
+		{"map", Map(numbers(3), double), scalars(0, 2, 4)},
+		{"map stream", Map(streamed(numbers(3), &opened), double), scalars(0, 2, 4)},
+		{"filter", Filter(numbers(5), even), scalars(0, 2, 4)},
+		{"take", Take(numbers(5), 2), scalars(0, 1)},
+		{"take more", Take(numbers(2), 5), scalars(0, 1)},
+		{"skip", Skip(numbers(5), 3), scalars(3, 4)},
+		{"skip more", Skip(numbers(2), 5), scalars()},
+		{"skip take", Take(Skip(numbers(6), 1), 3), scalars(1, 2, 3)},
+		{"take skip", Skip(Take(numbers(6), 4), 1), scalars(1, 2, 3)},
+		{"take stream", Take(Skip(streamed(numbers(6), &opened), 4), 3), scalars(4, 5)},
+		{"repeat", Repeat(numbers(2), 3), scalars(0, 1, 0, 1, 0, 1)},
+		{"repeat forever", Take(Repeat(numbers(2), 0), 5), scalars(0, 1, 0, 1, 0)},
+		{"repeat empty", Repeat(numbers(0), 0), scalars()},
+		{"batch", Batch(Repeat(numbers(3), 2), 4, false), [][]float64{{0, 1, 2, 0}, {1, 2}}},
+		{"batch drop", Batch(Repeat(numbers(3), 2), 4, true), [][]float64{{0, 1, 2, 0}}},
+		{"batch exact", Batch(numbers(4), 2, false), [][]float64{{0, 1}, {2, 3}}},
+		{"unbatch", Unbatch(Batch(Repeat(numbers(3), 2), 4, false)), scalars(0, 1, 2, 0, 1, 2)},
+		{"filter batch", Batch(Filter(numbers(7), even), 3, false), [][]float64{{0, 2, 4}, {6}}},
+		{"cache", Cache(streamed(Take(numbers(5), 3), &opened)), scalars(0, 1, 2)},
+		{"cache indexed", Cache(Map(numbers(3), double)), scalars(0, 2, 4)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// every iteration is a new pass
+			for pass := 0; pass < 2; pass++ {
+				if got := values(t, c.ds); !reflect.DeepEqual(got, c.want) {
+					t.Fatalf("pass %d: %v, want %v", pass, got, c.want)
+				}
+			}
+			if n := Len(c.ds); n >= 0 && n != len(c.want) {
+				t.Fatalf("Len is %d, want %d", n, len(c.want))
+			}
+		})
+	}
+}
+
+func TestCacheReadsOnce(t *testing.T) {
+	var opened int
+	ds := Cache(streamed(numbers(3), &opened))
+	for pass := 0; pass < 3; pass++ {
+		values(t, ds)
+	}
+	if opened != 1 {
+		t.Fatalf("the dataset was read %d times, want 1", opened)
+	}
+
+	// an iteration that does not reach the end is not kept
+	opened = 0
+	ds = Cache(streamed(numbers(3), &opened))
+	values(t, Take(ds, 2))
+	values(t, ds)
+	if opened != 2 {
+		t.Fatalf("the dataset was read %d times, want 2", opened)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	var opened int
+	cases := map[string]Dataset{
+		"indexed": Shuffle(numbers(10), 0, rand.New(rand.NewSource(1))),
+		"buffer":  Shuffle(streamed(numbers(10), &opened), 3, rand.New(rand.NewSource(1))),
+	}
+	for name, ds := range cases {
+		first := values(t, ds)
+		second := values(t, ds)
+		if reflect.DeepEqual(first, second) {
+			t.Errorf("%s: the same order in two passes: %v", name, first)
+		}
+		for _, got := range [][][]float64{first, second} {
+			var order []float64
+			for _, v := range got {
+				order = append(order, v[0])
+			}
+			sort.Float64s(order)
+			want := scalars(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+			if !reflect.DeepEqual(scalars(order...), want) {
+				t.Errorf("%s: %v is not a permutation of the samples", name, got)
+			}
+		}
+	}
+
+	it := Shuffle(streamed(numbers(3), &opened), 0, nil).Iter()
+	defer it.Close()
+	if _, err := it.Next(); err == nil {
+		t.Error("expected an error shuffling a stream without buffer")
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	fail := errors.New("fail")
+	ds := Map(numbers(3), func(s Sample) (Sample, error) {
+		if s.Input.GetData()[0] == 1 {
+			return Sample{}, fail
+		}
+		return s, nil
+	})
+	it := Batch(ds, 2, false).Iter()
+	defer it.Close()
+	if _, err := it.Next(); err != fail {
+		t.Fatalf("got %v, want the error of the map", err)
+	}
+
+	mixed := Batch(Map(numbers(2), func(s Sample) (Sample, error) {
+		if s.Input.GetData()[0] == 1 {
+			s.Input = tensor.NewZeroTensor(2)
+		}
+		return s, nil
+	}), 2, false).Iter()
+	defer mixed.Close()
+	if _, err := mixed.Next(); err == nil {
+		t.Fatal("expected an error batching samples of different shapes")
+	}
+}
@@ -0,0 +1,24 @@
+package initializer
+
+import "github.com/julioguillermo/neuralnetwork/pkg/tensor"
+
+type Constant struct {
+	Value float64
+}
+
+func NewConstant(value float64) *Constant {
+	return &Constant{value}
+}
+
+func NewZeros() *Constant {
+	return &Constant{0}
+}
+
+func (constant *Constant) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	for i := range data {
+		data[i] = constant.Value
+	}
+	return t
+}
@@ -0,0 +1,28 @@
+package initializer
+
+import "github.com/julioguillermo/neuralnetwork/pkg/tensor"
+
+// Initializer creates the initial values of a weight tensor of the given
+// shape. fanIn and fanOut are the number of inputs and outputs of every unit
+// of the layer that owns it. The random initializers draw from their Rand
+// or, if it is nil, from the shared source of package random.
+type Initializer interface {
+	Init(fanIn, fanOut int, shape ...int) tensor.Tensor
+}
+
+// Fans returns the fan-in and fan-out of a weight tensor with the
+// [outputs, inputs, kernel...] layout of the Dense and convolutional layers.
+func Fans(shape ...int) (int, int) {
+	if len(shape) == 0 {
+		return 1, 1
+	}
+	if len(shape) == 1 {
+		return shape[0], shape[0]
+	}
+	receptive := tensor.MulIndex(shape[2:], -1)
+	return shape[1] * receptive, shape[0] * receptive
+}
+
+// Default draws uniform values in [-1, 1], the initialization used by the
+// layers when they have no initializer.
+var Default = NewUniform(-1, 1)
@@ -0,0 +1,60 @@
+package initializer
+
+import (
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+type Normal struct {
+	Mean   float64
+	Stddev float64
+
+	Rand random.Source
+}
+
+func NewNormal(mean, stddev float64) *Normal {
+	return &Normal{Mean: mean, Stddev: stddev}
+}
+
+func (normal *Normal) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	src := random.Or(normal.Rand)
+	for i := range data {
+		data[i] = normal.Mean + src.NormFloat64()*normal.Stddev
+	}
+	return t
+}
+
+// TruncatedNormal draws normal values, drawing again the ones further than
+// two standard deviations from the mean.
+type TruncatedNormal struct {
+	Mean   float64
+	Stddev float64
+
+	Rand random.Source
+}
+
+func NewTruncatedNormal(mean, stddev float64) *TruncatedNormal {
+	return &TruncatedNormal{Mean: mean, Stddev: stddev}
+}
+
+func (normal *TruncatedNormal) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	src := random.Or(normal.Rand)
+	for i := range data {
+		data[i] = normal.Mean + truncated(src)*normal.Stddev
+	}
+	return t
+}
+
+// truncated returns a standard normal value in [-2, 2].
+func truncated(src random.Source) float64 {
+	for {
+		v := src.NormFloat64()
+		if v >= -2 && v <= 2 {
+			return v
+		}
+	}
+}
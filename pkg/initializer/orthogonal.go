@@ -0,0 +1,83 @@
+package initializer
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Orthogonal draws a random orthogonal matrix of shape[0] rows and the
+// product of the rest of the shape as columns, multiplied by Gain. The rows
+// are orthonormal if there are less rows than columns, otherwise the
+// columns are.
+type Orthogonal struct {
+	Gain float64
+
+	Rand random.Source
+}
+
+func NewOrthogonal(gain float64) *Orthogonal {
+	return &Orthogonal{Gain: gain}
+}
+
+func (orthogonal *Orthogonal) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	if len(data) == 0 {
+		return t
+	}
+	rows := 1
+	if len(shape) > 0 {
+		rows = shape[0]
+	}
+	cols := len(data) / rows
+	src := random.Or(orthogonal.Rand)
+	n, m := rows, cols
+	if n > m {
+		n, m = m, n
+	}
+
+	// Orthonormalize n random vectors of size m with Gram-Schmidt.
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		for {
+			v := make([]float64, m)
+			for j := range v {
+				v[j] = src.NormFloat64()
+			}
+			for _, u := range vectors[:i] {
+				dot := 0.0
+				for j := range v {
+					dot += v[j] * u[j]
+				}
+				for j := range v {
+					v[j] -= dot * u[j]
+				}
+			}
+			norm := 0.0
+			for _, x := range v {
+				norm += x * x
+			}
+			norm = math.Sqrt(norm)
+			if norm > 1e-10 {
+				for j := range v {
+					v[j] /= norm
+				}
+				vectors[i] = v
+				break
+			}
+		}
+	}
+
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if rows <= cols {
+				data[r*cols+c] = vectors[r][c] * orthogonal.Gain
+			} else {
+				data[r*cols+c] = vectors[c][r] * orthogonal.Gain
+			}
+		}
+	}
+	return t
+}
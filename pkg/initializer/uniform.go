@@ -0,0 +1,27 @@
+package initializer
+
+import (
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+type Uniform struct {
+	Min float64
+	Max float64
+
+	Rand random.Source
+}
+
+func NewUniform(min, max float64) *Uniform {
+	return &Uniform{Min: min, Max: max}
+}
+
+func (uniform *Uniform) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	src := random.Or(uniform.Rand)
+	for i := range data {
+		data[i] = uniform.Min + src.Float64()*(uniform.Max-uniform.Min)
+	}
+	return t
+}
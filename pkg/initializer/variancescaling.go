@@ -0,0 +1,101 @@
+package initializer
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// FanMode selects the fan a VarianceScaling divides its scale by.
+type FanMode int
+
+const (
+	// FanIn uses the number of inputs of every unit.
+	FanIn FanMode = iota
+	// FanOut uses the number of outputs of every unit.
+	FanOut
+	// FanAvg uses the mean of both.
+	FanAvg
+)
+
+// truncatedStddev is the standard deviation of a standard normal truncated
+// to [-2, 2].
+const truncatedStddev = 0.87962566103423978
+
+// VarianceScaling draws values with a variance of Scale divided by the fan
+// selected by Mode, from a uniform or, if Normal is set, from a truncated
+// normal distribution.
+type VarianceScaling struct {
+	Scale  float64
+	Mode   FanMode
+	Normal bool
+
+	Rand random.Source
+}
+
+func NewVarianceScaling(scale float64, mode FanMode, normal bool) *VarianceScaling {
+	return &VarianceScaling{
+		Scale:  scale,
+		Mode:   mode,
+		Normal: normal,
+	}
+}
+
+// NewGlorotUniform is also known as Xavier uniform.
+func NewGlorotUniform() *VarianceScaling {
+	return NewVarianceScaling(1, FanAvg, false)
+}
+
+// NewGlorotNormal is also known as Xavier normal.
+func NewGlorotNormal() *VarianceScaling {
+	return NewVarianceScaling(1, FanAvg, true)
+}
+
+// NewHeUniform is also known as Kaiming uniform.
+func NewHeUniform() *VarianceScaling {
+	return NewVarianceScaling(2, FanIn, false)
+}
+
+// NewHeNormal is also known as Kaiming normal.
+func NewHeNormal() *VarianceScaling {
+	return NewVarianceScaling(2, FanIn, true)
+}
+
+func NewLeCunUniform() *VarianceScaling {
+	return NewVarianceScaling(1, FanIn, false)
+}
+
+func NewLeCunNormal() *VarianceScaling {
+	return NewVarianceScaling(1, FanIn, true)
+}
+
+func (vs *VarianceScaling) Init(fanIn, fanOut int, shape ...int) tensor.Tensor {
+	fan := float64(fanIn)
+	switch vs.Mode {
+	case FanOut:
+		fan = float64(fanOut)
+	case FanAvg:
+		fan = float64(fanIn+fanOut) / 2
+	}
+	if fan < 1 {
+		fan = 1
+	}
+	variance := vs.Scale / fan
+
+	t := tensor.NewZeroTensor(shape...)
+	data := t.GetData()
+	src := random.Or(vs.Rand)
+	if vs.Normal {
+		stddev := math.Sqrt(variance) / truncatedStddev
+		for i := range data {
+			data[i] = truncated(src) * stddev
+		}
+	} else {
+		limit := math.Sqrt(3 * variance)
+		for i := range data {
+			data[i] = (src.Float64()*2 - 1) * limit
+		}
+	}
+	return t
+}
@@ -0,0 +1,434 @@
+package layer
+
+import (
+	"errors"
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// BatchNorm normalizes every feature (the last axis of the input, the units
+// of a Dense or the filters of a Conv2D) with its running mean and variance,
+// then scales and shifts it with the learned Gamma and Beta.
+//
+// The models are trained one sample at a time. In training mode an input
+// with many positions per feature, like the [w, h, c] output of a Conv2D, is
+// normalized with its own mean and variance, and the gradients go through
+// them. The running statistics take the place of the batch statistics for
+// the inputs with a single value per feature, like the output of a Dense,
+// and in inference mode. They are only updated in training mode, which means
+// Predict never changes them.
+type BatchNorm struct {
+	Gamma    tensor.Tensor
+	MGamma   tensor.Tensor
+	Beta     tensor.Tensor
+	MBeta    tensor.Tensor
+	Mean     tensor.Tensor
+	Variance tensor.Tensor
+	PreLayer Layer
+
+	Shape    []int
+	Features int
+	Momentum float64
+	Epsilon  float64
+
+	Trainable bool
+
+	training bool
+	cOutput  bool
+	output   tensor.Tensor
+	input    tensor.Tensor
+	dif      tensor.Tensor
+	sent     tensor.Tensor
+	cFit     bool
+	grads    []tensor.Tensor
+	cGrads   bool
+	cDif     int
+	mean     tensor.Tensor
+	variance tensor.Tensor
+	sample   bool
+	tape     *tape
+
+	wSL bool
+}
+
+func NewBatchNorm() *BatchNorm {
+	return &BatchNorm{
+		Momentum:  0.99,
+		Epsilon:   1e-3,
+		Trainable: true,
+	}
+}
+
+func (bn *BatchNorm) GetOutShape() []int {
+	return bn.Shape
+}
+
+func (bn *BatchNorm) Build() error {
+	if bn.Shape == nil || len(bn.Shape) < 1 {
+		return errors.New("invalid input shape")
+	}
+	bn.Features = bn.Shape[len(bn.Shape)-1]
+	if bn.Features < 1 {
+		return errors.New("invalid input shape")
+	}
+	bn.Gamma = tensor.NewOneTensor(bn.Features)
+	bn.MGamma = tensor.NewZeroTensor(bn.Features)
+	bn.Beta = tensor.NewZeroTensor(bn.Features)
+	bn.MBeta = tensor.NewZeroTensor(bn.Features)
+	bn.Mean = tensor.NewZeroTensor(bn.Features)
+	bn.Variance = tensor.NewOneTensor(bn.Features)
+	bn.PreLayer = nil
+	return nil
+}
+
+func (bn *BatchNorm) SetPrelayer(lay Layer) error {
+	if bn.PreLayer != nil && lay != nil && !tensor.CompareShape(bn.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	bn.PreLayer = lay
+	return nil
+}
+
+func (bn *BatchNorm) Connect(preLayer Layer) error {
+	bn.Shape = preLayer.GetOutShape()
+	err := bn.Build()
+	if err != nil {
+		return err
+	}
+	bn.PreLayer = preLayer
+	return nil
+}
+
+func (bn *BatchNorm) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (bn *BatchNorm) Reset() error {
+	if bn.cOutput || bn.cDif != 0 {
+		bn.cOutput = false
+		bn.cDif = 0
+		bn.cFit = false
+		bn.grads = nil
+		bn.cGrads = false
+		bn.sent = nil
+		if bn.PreLayer != nil {
+			return bn.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (bn *BatchNorm) FullReset() error {
+	return bn.Reset()
+}
+
+func (bn *BatchNorm) GetInput() tensor.Tensor {
+	return bn.input
+}
+
+func (bn *BatchNorm) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if bn.cOutput {
+		return bn.output, nil
+	}
+	if bn.PreLayer != nil {
+		var err error
+		input, err = bn.PreLayer.Output(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bn.GetOne(input)
+}
+
+func (bn *BatchNorm) setTape(tp *tape) {
+	bn.tape = tp
+}
+
+func (bn *BatchNorm) scale(f int) float64 {
+	v, _ := bn.variance.FGet(f)
+	return 1 / math.Sqrt(v+bn.Epsilon)
+}
+
+func (bn *BatchNorm) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if bn.cOutput {
+		return bn.output, nil
+	}
+	if input.Size() != tensor.MulIndex(bn.Shape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	input.Reshape(bn.Shape...)
+	bn.input = input
+	// mean and variance are the statistics of this output, which the
+	// gradients use.
+	bn.mean, bn.variance = bn.Mean, bn.Variance
+	bn.sample = false
+	if bn.training && bn.Trainable {
+		mean, variance := bn.statistics()
+		stats := bn.tape.keep(func() []tensor.Tensor {
+			bn.updateStatistics(mean, variance)
+			return []tensor.Tensor{bn.Mean.Copy(), bn.Variance.Copy()}
+		})
+		bn.mean, bn.variance = stats[0], stats[1]
+		if input.Size() > bn.Features {
+			bn.mean, bn.variance = mean, variance
+			bn.sample = true
+		}
+	}
+	out := tensor.NewZeroTensor(bn.Shape...)
+	data := out.GetData()
+	var f int
+	var g, b, m float64
+	for i, x := range input.GetData() {
+		f = i % bn.Features
+		g, _ = bn.Gamma.FGet(f)
+		b, _ = bn.Beta.FGet(f)
+		m, _ = bn.mean.FGet(f)
+		data[i] = g*(x-m)*bn.scale(f) + b
+	}
+	bn.output = out
+	bn.cOutput = true
+	return out, nil
+}
+
+func (bn *BatchNorm) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return bn.Get(input)
+}
+
+func (bn *BatchNorm) SetDif(dif tensor.Tensor) {
+	dif.Reshape(bn.Shape...)
+	if bn.cDif == 0 {
+		bn.dif = dif
+	} else {
+		bn.dif.AddTensor(dif)
+	}
+	bn.cDif++
+}
+
+func (bn *BatchNorm) Dif() error {
+	if bn.PreLayer != nil {
+		dif, err := unsentDif(bn.dif, &bn.sent)
+		if err != nil {
+			return err
+		}
+		der, err := bn.PreLayer.GetOne(bn.PreLayer.GetInput())
+		if err != nil {
+			return err
+		}
+		der.Reshape(bn.Shape...)
+		der, err = bn.PreLayer.GetActivation().Derive(der)
+		if err != nil {
+			return err
+		}
+
+		// With the statistics of the sample, every value changes the mean
+		// and variance of its feature, which adds the mean gradient of the
+		// feature and the one along the normalized values.
+		count := float64(bn.input.Size() / bn.Features)
+		difMean := make([]float64, bn.Features)
+		difNorm := make([]float64, bn.Features)
+		if bn.sample {
+			for i, d := range dif.GetData() {
+				f := i % bn.Features
+				difMean[f] += d / count
+				difNorm[f] += d * bn.normalized(i) / count
+			}
+		}
+
+		out := tensor.NewZeroTensor(bn.Shape...)
+		data := out.GetData()
+		var f int
+		var g, d float64
+		for i, dif := range dif.GetData() {
+			f = i % bn.Features
+			g, _ = bn.Gamma.FGet(f)
+			d, _ = der.FGet(i)
+			if bn.sample {
+				dif -= difMean[f] + bn.normalized(i)*difNorm[f]
+			}
+			data[i] = dif * g * bn.scale(f) * d
+		}
+
+		bn.PreLayer.SetDif(out)
+		err = bn.PreLayer.Dif()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bn *BatchNorm) SetTrainable(t bool) {
+	bn.Trainable = t
+}
+
+// statistics returns the mean and variance of every feature of the current
+// input.
+func (bn *BatchNorm) statistics() (tensor.Tensor, tensor.Tensor) {
+	count := float64(bn.input.Size() / bn.Features)
+	mean := tensor.NewZeroTensor(bn.Features)
+	m := mean.GetData()
+	for i, x := range bn.input.GetData() {
+		m[i%bn.Features] += x / count
+	}
+	variance := tensor.NewZeroTensor(bn.Features)
+	v := variance.GetData()
+	for i, x := range bn.input.GetData() {
+		f := i % bn.Features
+		v[f] += (x - m[f]) * (x - m[f]) / count
+	}
+	return mean, variance
+}
+
+// updateStatistics moves the running mean and variance toward the ones of
+// the current input.
+func (bn *BatchNorm) updateStatistics(mean, variance tensor.Tensor) {
+	var m, s float64
+	for f := 0; f < bn.Features; f++ {
+		m, _ = bn.Mean.FGet(f)
+		s, _ = mean.FGet(f)
+		bn.Mean.FSet(bn.Momentum*m+(1-bn.Momentum)*s, f)
+		m, _ = bn.Variance.FGet(f)
+		s, _ = variance.FGet(f)
+		bn.Variance.FSet(bn.Momentum*m+(1-bn.Momentum)*s, f)
+	}
+}
+
+// normalized returns the value i of the current input normalized with the
+// statistics of the output.
+func (bn *BatchNorm) normalized(i int) float64 {
+	f := i % bn.Features
+	x, _ := bn.input.FGet(i)
+	m, _ := bn.mean.FGet(f)
+	return (x - m) * bn.scale(f)
+}
+
+func (bn *BatchNorm) SetTraining(t bool) {
+	bn.training = t
+	if bn.PreLayer != nil {
+		bn.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of Gamma and Beta for the last input,
+// once per step.
+func (bn *BatchNorm) calGrads() []tensor.Tensor {
+	if bn.grads != nil {
+		return bn.grads
+	}
+	gamma := tensor.NewZeroTensor(bn.Features)
+	beta := tensor.NewZeroTensor(bn.Features)
+	var f int
+	var x, m float64
+	for i, d := range bn.dif.GetData() {
+		f = i % bn.Features
+		x, _ = bn.input.FGet(i)
+		m, _ = bn.mean.FGet(f)
+		gamma.AddAt(d*(x-m)*bn.scale(f), f)
+		beta.AddAt(d, f)
+	}
+	bn.grads = []tensor.Tensor{gamma, beta}
+	return bn.grads
+}
+
+func (bn *BatchNorm) Fit(alpha float64, momentum float64) error {
+	if bn.cFit {
+		return nil
+	}
+	bn.cFit = true
+	if bn.Trainable {
+		update(alpha, momentum,
+			[]tensor.Tensor{bn.Gamma, bn.Beta},
+			[]tensor.Tensor{bn.MGamma, bn.MBeta},
+			bn.calGrads())
+	}
+	if bn.PreLayer != nil {
+		return bn.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+func (bn *BatchNorm) GetPenalty() (float64, error) {
+	if bn.PreLayer != nil {
+		return bn.PreLayer.GetPenalty()
+	}
+	return 0, nil
+}
+
+func (bn *BatchNorm) GetGradients() ([][]tensor.Tensor, error) {
+	if bn.cGrads {
+		return nil, nil
+	}
+	bn.cGrads = true
+	var grads [][]tensor.Tensor
+	if bn.Trainable {
+		grads = append(grads, bn.calGrads())
+	}
+	if bn.PreLayer != nil {
+		pg, err := bn.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+func (bn *BatchNorm) ResetSL() error {
+	bn.wSL = false
+	if bn.PreLayer != nil {
+		return bn.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+func (bn *BatchNorm) GetWeights() (serialization.Weights, error) {
+	if bn.wSL {
+		return serialization.Weights{}, nil
+	}
+	bn.wSL = true
+	data := [][]float64{
+		bn.Gamma.GetData(),
+		bn.Beta.GetData(),
+		bn.Mean.GetData(),
+		bn.Variance.GetData(),
+	}
+
+	w := serialization.Weights{
+		Data: data,
+	}
+
+	if bn.PreLayer != nil {
+		pw, e := bn.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+		w.PreWeights = []serialization.Weights{pw}
+	}
+	return w, nil
+}
+
+func (bn *BatchNorm) SetWeights(w serialization.Weights) error {
+	if !bn.wSL {
+		bn.wSL = true
+		if w.Data != nil {
+			if len(w.Data) < 4 {
+				return errors.New("invalid weights len")
+			}
+			bn.Gamma.SetData(w.Data[0])
+			bn.Beta.SetData(w.Data[1])
+			bn.Mean.SetData(w.Data[2])
+			bn.Variance.SetData(w.Data[3])
+		}
+
+		if bn.PreLayer != nil && w.PreWeights != nil {
+			if len(w.PreWeights) == 0 {
+				return errors.New("invalid preWeights len")
+			}
+			return bn.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
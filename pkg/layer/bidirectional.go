@@ -0,0 +1,588 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Bidirectional runs two recurrent layers over a [steps, features...] input,
+// Forward from the first step to the last and Backward from the last step to
+// the first, and merges their outputs at every step. The wrapped layers start
+// from a clean state (FullReset) for every input sequence, and they are
+// fitted once per sequence with the sum of the gradients of its steps.
+type Bidirectional struct {
+	Forward   Layer
+	Backward  Layer
+	Merge     MergeMode
+	PreLayer  Layer
+	InShape   []int
+	Shape     []int
+	UnitsSize int
+
+	Trainable bool
+
+	fwIn   *step
+	bwIn   *step
+	fwTape *tape
+	bwTape *tape
+	steps  []tensor.Tensor
+	fwOut  []tensor.Tensor
+	bwOut  []tensor.Tensor
+	fwDer  []tensor.Tensor
+	bwDer  []tensor.Tensor
+	// penalty is the one of both layers at every step.
+	penalty float64
+
+	cOutput  bool
+	output   tensor.Tensor
+	input    tensor.Tensor
+	dif      tensor.Tensor
+	sent     tensor.Tensor
+	cFit     bool
+	grads    [][]tensor.Tensor
+	cGrads   bool
+	cPenalty bool
+	cDif     int
+
+	wSL bool
+}
+
+func NewBidirectional(forward, backward Layer, merge MergeMode) *Bidirectional {
+	return &Bidirectional{
+		Forward:   forward,
+		Backward:  backward,
+		Merge:     merge,
+		Trainable: true,
+	}
+}
+
+func NewInBidirectional(inShape []int, forward, backward Layer, merge MergeMode) *Bidirectional {
+	return &Bidirectional{
+		Forward:   forward,
+		Backward:  backward,
+		Merge:     merge,
+		InShape:   inShape,
+		Trainable: true,
+	}
+}
+
+func (bi *Bidirectional) GetOutShape() []int {
+	return bi.Shape
+}
+
+func (bi *Bidirectional) Build() error {
+	if bi.InShape == nil || len(bi.InShape) < 2 || bi.InShape[0] < 1 {
+		return errors.New("invalid input shape")
+	}
+	if bi.Forward == nil || bi.Backward == nil {
+		return errors.New("no layers given")
+	}
+	bi.fwIn = newStep(bi.InShape[1:]...)
+	bi.bwIn = newStep(bi.InShape[1:]...)
+	err := bi.Forward.Connect(bi.fwIn)
+	if err != nil {
+		return err
+	}
+	err = bi.Backward.Connect(bi.bwIn)
+	if err != nil {
+		return err
+	}
+	bi.fwTape = newTape(bi.Forward)
+	bi.bwTape = newTape(bi.Backward)
+	size := tensor.MulIndex(bi.Forward.GetOutShape(), -1)
+	if size != tensor.MulIndex(bi.Backward.GetOutShape(), -1) {
+		return errors.New("incompatible layers outputs shape")
+	}
+	bi.UnitsSize = size
+	switch bi.Merge {
+	case MergeConcat:
+		bi.Shape = []int{bi.InShape[0], size * 2}
+	case MergeSum, MergeAverage:
+		bi.Shape = []int{bi.InShape[0], size}
+	default:
+		return errors.New("invalid merge mode")
+	}
+	bi.PreLayer = nil
+	return nil
+}
+
+func (bi *Bidirectional) SetPrelayer(lay Layer) error {
+	if bi.PreLayer != nil && lay != nil && !tensor.CompareShape(bi.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	bi.PreLayer = lay
+	return nil
+}
+
+func (bi *Bidirectional) Connect(preLayer Layer) error {
+	bi.InShape = preLayer.GetOutShape()
+	err := bi.Build()
+	if err != nil {
+		return err
+	}
+	bi.PreLayer = preLayer
+	return nil
+}
+
+func (bi *Bidirectional) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (bi *Bidirectional) Reset() error {
+	if bi.cOutput || bi.cDif != 0 {
+		bi.cOutput = false
+		bi.cDif = 0
+		bi.cFit = false
+		bi.grads = nil
+		bi.cGrads = false
+		bi.cPenalty = false
+		bi.sent = nil
+		if bi.PreLayer != nil {
+			return bi.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (bi *Bidirectional) FullReset() error {
+	return bi.Reset()
+}
+
+func (bi *Bidirectional) GetInput() tensor.Tensor {
+	return bi.input
+}
+
+func (bi *Bidirectional) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if bi.cOutput {
+		return bi.output, nil
+	}
+	if bi.PreLayer != nil {
+		var err error
+		input, err = bi.PreLayer.Output(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bi.GetOne(input)
+}
+
+// runStep feeds the step t of the current sequence to lay, returning its
+// output and the derivative of its activation.
+func (bi *Bidirectional) runStep(lay Layer, in *step, t int) (tensor.Tensor, tensor.Tensor, error) {
+	err := lay.Reset()
+	if err != nil {
+		return nil, nil, err
+	}
+	in.value = bi.steps[t]
+	out, err := lay.Output(in.value)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := lay.GetOne(lay.GetInput())
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err = lay.GetActivation().Derive(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, der, nil
+}
+
+// order returns the step processed at position i by the given direction.
+func (bi *Bidirectional) order(backward bool, i int) int {
+	if backward {
+		return bi.InShape[0] - 1 - i
+	}
+	return i
+}
+
+// run feeds the sequence to the wrapped layer of the given direction,
+// recording what it draws in training mode for the replays.
+func (bi *Bidirectional) run(backward bool) ([]tensor.Tensor, []tensor.Tensor, error) {
+	lay, in, tp := bi.Forward, bi.fwIn, bi.fwTape
+	if backward {
+		lay, in, tp = bi.Backward, bi.bwIn, bi.bwTape
+	}
+	err := lay.FullReset()
+	if err != nil {
+		return nil, nil, err
+	}
+	tp.record()
+	defer tp.replay()
+	outs := make([]tensor.Tensor, bi.InShape[0])
+	ders := make([]tensor.Tensor, bi.InShape[0])
+	var p float64
+	for i := 0; i < bi.InShape[0]; i++ {
+		t := bi.order(backward, i)
+		outs[t], ders[t], err = bi.runStep(lay, in, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		p, err = lay.GetPenalty()
+		if err != nil {
+			return nil, nil, err
+		}
+		bi.penalty += p
+	}
+	return outs, ders, nil
+}
+
+func (bi *Bidirectional) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if bi.cOutput {
+		return bi.output, nil
+	}
+	if input.Size() != tensor.MulIndex(bi.InShape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	input = input.Copy()
+	input.Reshape(bi.InShape...)
+	bi.input = input
+	bi.steps = make([]tensor.Tensor, bi.InShape[0])
+	var err error
+	for t := range bi.steps {
+		bi.steps[t], err = input.GetSubTensor(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	bi.penalty = 0
+	bi.fwOut, bi.fwDer, err = bi.run(false)
+	if err != nil {
+		return nil, err
+	}
+	bi.bwOut, bi.bwDer, err = bi.run(true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := tensor.NewZeroTensor(bi.Shape...)
+	data := out.GetData()
+	for t := 0; t < bi.InShape[0]; t++ {
+		fw := bi.fwOut[t].GetData()
+		bw := bi.bwOut[t].GetData()
+		offset := t * bi.Shape[1]
+		for i := 0; i < bi.UnitsSize; i++ {
+			switch bi.Merge {
+			case MergeConcat:
+				data[offset+i] = fw[i]
+				data[offset+bi.UnitsSize+i] = bw[i]
+			case MergeSum:
+				data[offset+i] = fw[i] + bw[i]
+			case MergeAverage:
+				data[offset+i] = (fw[i] + bw[i]) / 2
+			}
+		}
+	}
+	bi.output = out
+	bi.cOutput = true
+	return out, nil
+}
+
+func (bi *Bidirectional) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return bi.Get(input)
+}
+
+func (bi *Bidirectional) SetDif(dif tensor.Tensor) {
+	dif.Reshape(bi.Shape...)
+	if bi.cDif == 0 {
+		bi.dif = dif
+	} else {
+		bi.dif.AddTensor(dif)
+	}
+	bi.cDif++
+}
+
+// splitDif turns the gradient dif of the merged output into the gradients
+// of the neta of both wrapped layers at every step.
+func (bi *Bidirectional) splitDif(dif tensor.Tensor) ([]tensor.Tensor, []tensor.Tensor) {
+	steps := bi.InShape[0]
+	fwDif := make([]tensor.Tensor, steps)
+	bwDif := make([]tensor.Tensor, steps)
+	data := dif.GetData()
+	for t := 0; t < steps; t++ {
+		fw := make([]float64, bi.UnitsSize)
+		bw := make([]float64, bi.UnitsSize)
+		fwDer := bi.fwDer[t].GetData()
+		bwDer := bi.bwDer[t].GetData()
+		offset := t * bi.Shape[1]
+		for i := 0; i < bi.UnitsSize; i++ {
+			switch bi.Merge {
+			case MergeConcat:
+				fw[i] = data[offset+i]
+				bw[i] = data[offset+bi.UnitsSize+i]
+			case MergeSum:
+				fw[i] = data[offset+i]
+				bw[i] = data[offset+i]
+			case MergeAverage:
+				fw[i] = data[offset+i] / 2
+				bw[i] = data[offset+i] / 2
+			}
+			fw[i] *= fwDer[i]
+			bw[i] *= bwDer[i]
+		}
+		fwDif[t] = tensor.NewTensor(fw, bi.Forward.GetOutShape()...)
+		bwDif[t] = tensor.NewTensor(bw, bi.Backward.GetOutShape()...)
+	}
+	return fwDif, bwDif
+}
+
+// replay runs again the wrapped layer of the given direction over the
+// sequence, with the values recorded by run, giving it the gradient of
+// every step. The gradients of the inputs are added to out if it is not
+// nil, and the gradients of the layer are added to grads if it is not nil.
+// The layer is left at the last step, ready to be fitted with the sum.
+func (bi *Bidirectional) replay(backward bool, difs []tensor.Tensor, out tensor.Tensor, grads *stepGrads) error {
+	lay, in, tp := bi.Forward, bi.fwIn, bi.fwTape
+	if backward {
+		lay, in, tp = bi.Backward, bi.bwIn, bi.bwTape
+	}
+	err := lay.FullReset()
+	if err != nil {
+		return err
+	}
+	tp.replay()
+	size := in.Size
+	for i := 0; i < bi.InShape[0]; i++ {
+		t := bi.order(backward, i)
+		_, _, err = bi.runStep(lay, in, t)
+		if err != nil {
+			return err
+		}
+		lay.SetDif(difs[t].Copy())
+		if grads != nil {
+			err = grads.add(lay)
+			if err != nil {
+				return err
+			}
+		}
+		if out == nil {
+			continue
+		}
+		err = lay.Dif()
+		if err != nil {
+			return err
+		}
+		data := out.GetData()
+		for j, d := range in.dif.GetData() {
+			data[t*size+j] += d
+		}
+	}
+	return nil
+}
+
+func (bi *Bidirectional) Dif() error {
+	dif, err := unsentDif(bi.dif, &bi.sent)
+	if err != nil {
+		return err
+	}
+	if bi.PreLayer != nil {
+		// The replays leave the wrapped layers at other gradients.
+		bi.grads = nil
+		fwDif, bwDif := bi.splitDif(dif)
+		out := tensor.NewZeroTensor(bi.InShape...)
+		err = bi.replay(false, fwDif, out, nil)
+		if err != nil {
+			return err
+		}
+		err = bi.replay(true, bwDif, out, nil)
+		if err != nil {
+			return err
+		}
+
+		der, err := bi.PreLayer.GetOne(bi.PreLayer.GetInput())
+		if err != nil {
+			return err
+		}
+		der.Reshape(bi.InShape...)
+		der, err = bi.PreLayer.GetActivation().Derive(der)
+		if err != nil {
+			return err
+		}
+		err = out.MulTensor(der)
+		if err != nil {
+			return err
+		}
+
+		bi.PreLayer.SetDif(out)
+		err = bi.PreLayer.Dif()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bi *Bidirectional) SetTrainable(t bool) {
+	bi.Trainable = t
+}
+
+func (bi *Bidirectional) SetTraining(t bool) {
+	bi.Forward.SetTraining(t)
+	bi.Backward.SetTraining(t)
+	if bi.PreLayer != nil {
+		bi.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of the Forward and Backward layers for
+// the whole sequence, once per step.
+func (bi *Bidirectional) calGrads() ([][]tensor.Tensor, error) {
+	if bi.grads != nil {
+		return bi.grads, nil
+	}
+	if bi.cDif == 0 {
+		return [][]tensor.Tensor{}, nil
+	}
+	fwDif, bwDif := bi.splitDif(bi.dif)
+	var fw, bw stepGrads
+	err := bi.replay(false, fwDif, nil, &fw)
+	if err != nil {
+		return nil, err
+	}
+	err = bi.replay(true, bwDif, nil, &bw)
+	if err != nil {
+		return nil, err
+	}
+	bi.grads = append(fw.done(), bw.done()...)
+	return bi.grads, nil
+}
+
+func (bi *Bidirectional) Fit(alpha float64, momentum float64) error {
+	if bi.cFit {
+		return nil
+	}
+	bi.cFit = true
+	if bi.Trainable {
+		_, err := bi.calGrads()
+		if err != nil {
+			return err
+		}
+		err = bi.Forward.Fit(alpha, momentum)
+		if err != nil {
+			return err
+		}
+		err = bi.Backward.Fit(alpha, momentum)
+		if err != nil {
+			return err
+		}
+	}
+	if bi.PreLayer != nil {
+		return bi.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+// GetPenalty returns the penalty of the Forward and Backward layers summed
+// over the steps of the last input, counted once until the next Reset, and
+// the one of the prelayer.
+func (bi *Bidirectional) GetPenalty() (float64, error) {
+	penalty := 0.0
+	if !bi.cPenalty {
+		bi.cPenalty = true
+		penalty = bi.penalty
+	}
+	if bi.PreLayer != nil {
+		p, err := bi.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+// GetGradients returns the gradients of the Forward and Backward layers,
+// summed over the steps of the sequence, and the ones of the prelayer.
+func (bi *Bidirectional) GetGradients() ([][]tensor.Tensor, error) {
+	if bi.cGrads {
+		return nil, nil
+	}
+	bi.cGrads = true
+	var grads [][]tensor.Tensor
+	if bi.Trainable {
+		g, err := bi.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, g...)
+	}
+	if bi.PreLayer != nil {
+		pg, err := bi.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+func (bi *Bidirectional) ResetSL() error {
+	bi.wSL = false
+	err := bi.Forward.ResetSL()
+	if err != nil {
+		return err
+	}
+	err = bi.Backward.ResetSL()
+	if err != nil {
+		return err
+	}
+	if bi.PreLayer != nil {
+		return bi.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+// GetWeights keeps the weights of the prelayer at PreWeights[0] and the ones
+// of the Forward and Backward layers at PreWeights[1] and PreWeights[2].
+func (bi *Bidirectional) GetWeights() (serialization.Weights, error) {
+	if bi.wSL {
+		return serialization.Weights{}, nil
+	}
+	bi.wSL = true
+	fw, e := bi.Forward.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	bw, e := bi.Backward.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	pw := serialization.Weights{}
+	if bi.PreLayer != nil {
+		pw, e = bi.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw, fw, bw},
+	}, nil
+}
+
+func (bi *Bidirectional) SetWeights(w serialization.Weights) error {
+	if !bi.wSL {
+		bi.wSL = true
+		if w.PreWeights == nil {
+			return nil
+		}
+		if len(w.PreWeights) < 3 {
+			return errors.New("invalid preWeights len")
+		}
+		err := bi.Forward.SetWeights(w.PreWeights[1])
+		if err != nil {
+			return err
+		}
+		err = bi.Backward.SetWeights(w.PreWeights[2])
+		if err != nil {
+			return err
+		}
+		if bi.PreLayer != nil {
+			return bi.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
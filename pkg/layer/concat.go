@@ -18,6 +18,7 @@ type Concat struct {
 	der     tensor.Tensor
 	cDer    bool
 	dif     tensor.Tensor
+	sent    tensor.Tensor
 	cDif    int
 
 	wSL bool
@@ -79,6 +80,7 @@ func (concat *Concat) Reset() error {
 	if concat.cOutput || concat.cDif != 0 {
 		concat.cOutput = false
 		concat.cDif = 0
+		concat.sent = nil
 		var e error
 		for _, l := range concat.PreLayers {
 			e = l.Reset()
@@ -153,10 +155,13 @@ func (concat *Concat) SetDif(dif tensor.Tensor) {
 }
 
 func (concat *Concat) Dif() error {
+	dif, e := unsentDif(concat.dif, &concat.sent)
+	if e != nil {
+		return e
+	}
 	var t tensor.Tensor
-	var e error
 	for i, l := range concat.PreLayers {
-		t, e = concat.dif.GetSubTensor(i)
+		t, e = dif.GetSubTensor(i)
 		if e != nil {
 			return e
 		}
@@ -171,6 +176,12 @@ func (concat *Concat) Dif() error {
 
 func (concat *Concat) SetTrainable(bool) {}
 
+func (concat *Concat) SetTraining(t bool) {
+	for _, l := range concat.PreLayers {
+		l.SetTraining(t)
+	}
+}
+
 func (concat *Concat) Fit(alpha, momentum float64) error {
 	var e error
 	for _, l := range concat.PreLayers {
@@ -182,6 +193,30 @@ func (concat *Concat) Fit(alpha, momentum float64) error {
 	return nil
 }
 
+func (concat *Concat) GetPenalty() (float64, error) {
+	penalty := 0.0
+	for _, l := range concat.PreLayers {
+		p, e := l.GetPenalty()
+		if e != nil {
+			return 0, e
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (concat *Concat) GetGradients() ([][]tensor.Tensor, error) {
+	var grads [][]tensor.Tensor
+	for _, l := range concat.PreLayers {
+		g, e := l.GetGradients()
+		if e != nil {
+			return nil, e
+		}
+		grads = append(grads, g...)
+	}
+	return grads, nil
+}
+
 func (concat *Concat) ResetSL() error {
 	concat.wSL = false
 	var e error
@@ -0,0 +1,534 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Conv is the convolution of a channels-last input with any number of
+// spatial axes: [steps, c] for Conv1D and [w, h, d, c] for Conv3D. Kernel,
+// Stride, Dilation and Pad have one value per spatial axis and work as the
+// ones of Conv2D; a Transposed Conv works as Deconv2D.
+//
+// Its weights are stored as the ones of Conv2D: Weights is
+// [filters, channels/Groups, kernel...] and Bias has the output shape.
+type Conv struct {
+	Weights           tensor.Tensor
+	MWeights          tensor.Tensor
+	Bias              tensor.Tensor
+	MBias             tensor.Tensor
+	Activation        activation.Activation
+	KernelInitializer initializer.Initializer
+	BiasInitializer   initializer.Initializer
+	PreLayer          Layer
+
+	InputShape  []int
+	OutputShape []int
+	Filters     int
+	Transposed  bool
+	Kernel      []int
+	Stride      []int
+	Padding     Padding
+	Pad         []int
+	Dilation    []int
+	Groups      int
+
+	taps [][3]int
+
+	cNeta   bool
+	cOutput bool
+	cDif    int
+	neta    tensor.Tensor
+	output  tensor.Tensor
+	input   tensor.Tensor
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cFit    bool
+	grads   []tensor.Tensor
+	cGrads  bool
+
+	Regularization
+
+	Trainable bool
+	wSL       bool
+}
+
+func newConv(transposed bool, filters, stride int, kernel []int, act activation.Activation) *Conv {
+	conv := &Conv{
+		Filters:    filters,
+		Transposed: transposed,
+		Kernel:     kernel,
+		Stride:     make([]int, len(kernel)),
+		Pad:        make([]int, len(kernel)),
+		Dilation:   make([]int, len(kernel)),
+		Groups:     1,
+		Activation: act,
+		Trainable:  true,
+	}
+	for i := range kernel {
+		conv.Stride[i] = stride
+		conv.Dilation[i] = 1
+	}
+	return conv
+}
+
+func NewConv1D(filters, k, stride int, act activation.Activation) *Conv {
+	return newConv(false, filters, stride, []int{k}, act)
+}
+
+func NewInConv1D(input_shape []int, filters, k, stride int, act activation.Activation) *Conv {
+	conv := NewConv1D(filters, k, stride, act)
+	conv.InputShape = input_shape
+	return conv
+}
+
+func NewConv3D(filters, kw, kh, kd, stride int, act activation.Activation) *Conv {
+	return newConv(false, filters, stride, []int{kw, kh, kd}, act)
+}
+
+func NewInConv3D(input_shape []int, filters, kw, kh, kd, stride int, act activation.Activation) *Conv {
+	conv := NewConv3D(filters, kw, kh, kd, stride, act)
+	conv.InputShape = input_shape
+	return conv
+}
+
+func NewDeconv1D(filters, k, stride int, act activation.Activation) *Conv {
+	return newConv(true, filters, stride, []int{k}, act)
+}
+
+func NewInDeconv1D(input_shape []int, filters, k, stride int, act activation.Activation) *Conv {
+	conv := NewDeconv1D(filters, k, stride, act)
+	conv.InputShape = input_shape
+	return conv
+}
+
+func NewDeconv3D(filters, kw, kh, kd, stride int, act activation.Activation) *Conv {
+	return newConv(true, filters, stride, []int{kw, kh, kd}, act)
+}
+
+func NewInDeconv3D(input_shape []int, filters, kw, kh, kd, stride int, act activation.Activation) *Conv {
+	conv := NewDeconv3D(filters, kw, kh, kd, stride, act)
+	conv.InputShape = input_shape
+	return conv
+}
+
+func (conv *Conv) GetOutShape() []int {
+	return conv.OutputShape
+}
+
+func (conv *Conv) calOutShape() {
+	dims := len(conv.Kernel)
+	conv.OutputShape = make([]int, dims+1)
+	for i := 0; i < dims; i++ {
+		if conv.Transposed {
+			conv.OutputShape[i], conv.Pad[i] = deconvSize(conv.InputShape[i], conv.Kernel[i], conv.Stride[i], conv.Dilation[i], conv.Pad[i], conv.Padding)
+		} else {
+			conv.OutputShape[i], conv.Pad[i] = convSize(conv.InputShape[i], conv.Kernel[i], conv.Stride[i], conv.Dilation[i], conv.Pad[i], conv.Padding)
+		}
+	}
+	conv.OutputShape[dims] = conv.Filters
+}
+
+// calTaps lists every (output position, input position, kernel position)
+// linked by the kernel, the positions are flat spatial indices.
+func (conv *Conv) calTaps() {
+	dims := len(conv.Kernel)
+	in := conv.InputShape[:dims]
+	out := conv.OutputShape[:dims]
+	from, to := out, in
+	if conv.Transposed {
+		from, to = in, out
+	}
+	conv.taps = nil
+	pos := make([]int, dims)
+	offset := make([]int, dims)
+	for p := 0; p < tensor.MulIndex(from, -1); p++ {
+		for k := 0; k < tensor.MulIndex(conv.Kernel, -1); k++ {
+			index := 0
+			inside := true
+			for i := range pos {
+				q := pos[i]*conv.Stride[i] - conv.Pad[i] + offset[i]*conv.Dilation[i]
+				if q < 0 || q >= to[i] {
+					inside = false
+					break
+				}
+				index = index*to[i] + q
+			}
+			if inside {
+				if conv.Transposed {
+					conv.taps = append(conv.taps, [3]int{index, p, k})
+				} else {
+					conv.taps = append(conv.taps, [3]int{p, index, k})
+				}
+			}
+			nextIndex(offset, conv.Kernel)
+		}
+		nextIndex(pos, from)
+	}
+}
+
+func (conv *Conv) Build() error {
+	dims := len(conv.Kernel)
+	if conv.InputShape == nil || len(conv.InputShape) != dims+1 {
+		return errors.New("invalid input shape")
+	}
+	for _, v := range conv.InputShape {
+		if v < 1 {
+			return errors.New("invalid input shape")
+		}
+	}
+	if conv.Filters < 1 {
+		return errors.New("invalid output shape")
+	}
+	if len(conv.Stride) != dims || len(conv.Dilation) != dims || len(conv.Pad) != dims {
+		return errors.New("invalid stride, dilation or padding")
+	}
+	for i := 0; i < dims; i++ {
+		if conv.Kernel[i] < 1 || conv.Stride[i] < 1 || conv.Dilation[i] < 1 {
+			return errors.New("invalid kernel, stride or dilation")
+		}
+	}
+	if conv.Groups < 1 || conv.InputShape[dims]%conv.Groups != 0 || conv.Filters%conv.Groups != 0 {
+		return errors.New("invalid groups")
+	}
+	if conv.Activation == nil {
+		conv.Activation = &activation.Relu{}
+	}
+	if conv.KernelInitializer == nil {
+		conv.KernelInitializer = initializer.Default
+	}
+	if conv.BiasInitializer == nil {
+		conv.BiasInitializer = initializer.Default
+	}
+	conv.calOutShape()
+	for i := 0; i < dims; i++ {
+		if conv.OutputShape[i] < 1 {
+			return errors.New("invalid output shape")
+		}
+	}
+	conv.calTaps()
+	conv.PreLayer = nil
+	wShape := append([]int{conv.Filters, conv.InputShape[dims] / conv.Groups}, conv.Kernel...)
+	fanIn, fanOut := initializer.Fans(wShape...)
+	conv.Weights = conv.KernelInitializer.Init(fanIn, fanOut, wShape...)
+	conv.MWeights = tensor.NewZeroTensor(wShape...)
+	conv.Bias = conv.BiasInitializer.Init(fanIn, fanOut, conv.OutputShape...)
+	conv.MBias = tensor.NewZeroTensor(conv.OutputShape...)
+	return nil
+}
+
+func (conv *Conv) SetPrelayer(lay Layer) error {
+	if conv.PreLayer != nil && lay != nil && !tensor.CompareShape(conv.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	conv.PreLayer = lay
+	return nil
+}
+
+func (conv *Conv) Connect(preLayer Layer) error {
+	conv.InputShape = preLayer.GetOutShape()
+	err := conv.Build()
+	if err != nil {
+		return err
+	}
+	conv.PreLayer = preLayer
+	return nil
+}
+
+func (conv *Conv) GetActivation() activation.Activation {
+	return conv.Activation
+}
+
+func (conv *Conv) Reset() error {
+	if conv.cNeta || conv.cOutput || conv.cDif != 0 {
+		conv.cNeta = false
+		conv.cOutput = false
+		conv.cDif = 0
+		conv.cFit = false
+		conv.sent = nil
+		conv.resetPenalty()
+		conv.grads = nil
+		conv.cGrads = false
+		if conv.PreLayer != nil {
+			return conv.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) FullReset() error {
+	return conv.Reset()
+}
+
+func (conv *Conv) GetInput() tensor.Tensor {
+	return conv.input
+}
+
+func (conv *Conv) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if conv.cNeta {
+		return conv.neta, nil
+	}
+	if conv.PreLayer != nil {
+		var e error
+		input, e = conv.PreLayer.Output(input)
+		if e != nil {
+			return nil, e
+		}
+	}
+	return conv.GetOne(input)
+}
+
+// each calls f for every weight link between an output value and an input
+// value, with their flat indices and the flat index of the weight.
+func (conv *Conv) each(f func(out, in, w int)) {
+	dims := len(conv.Kernel)
+	channels := conv.InputShape[dims]
+	group := channels / conv.Groups
+	filters := conv.Filters / conv.Groups
+	kernel := tensor.MulIndex(conv.Kernel, -1)
+	for _, tap := range conv.taps {
+		for od := 0; od < conv.Filters; od++ {
+			first := od / filters * group
+			for id := 0; id < group; id++ {
+				f(tap[0]*conv.Filters+od, tap[1]*channels+first+id, (od*group+id)*kernel+tap[2])
+			}
+		}
+	}
+}
+
+func (conv *Conv) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if conv.cNeta {
+		return conv.neta, nil
+	}
+	if len(input.GetShape()) != len(conv.InputShape) {
+		return nil, errors.New("incompatible input shape")
+	}
+	for i, v := range input.GetShape() {
+		if v != conv.InputShape[i] {
+			return nil, errors.New("incompatible input shape")
+		}
+	}
+	conv.input = input
+	out := conv.Bias.Copy()
+	data := out.GetData()
+	in := input.GetData()
+	weights := conv.Weights.GetData()
+	conv.each(func(o, i, w int) {
+		data[o] += weights[w] * in[i]
+	})
+	conv.neta = out
+	return out, nil
+}
+
+func (conv *Conv) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	if conv.cOutput {
+		return conv.output, nil
+	}
+	var err error
+	input, err = conv.Get(input)
+	if err != nil {
+		return nil, err
+	}
+	conv.output, err = conv.Activation.Activate(input)
+	if err != nil {
+		return nil, err
+	}
+	conv.cOutput = true
+	return conv.output, nil
+}
+
+func (conv *Conv) SetDif(dif tensor.Tensor) {
+	dif.Reshape(conv.OutputShape...)
+	if conv.cDif == 0 {
+		conv.dif = dif
+	} else {
+		conv.dif.AddTensor(dif)
+	}
+	conv.cDif++
+}
+
+func (conv *Conv) Dif() error {
+	err := conv.addActivityDif(&conv.dif, conv.Activation, conv.neta, conv.output)
+	if err != nil {
+		return err
+	}
+	if conv.PreLayer != nil {
+		dif, err := unsentDif(conv.dif, &conv.sent)
+		if err != nil {
+			return err
+		}
+		der, err := conv.PreLayer.GetOne(conv.PreLayer.GetInput())
+		if err != nil {
+			return err
+		}
+		der.Reshape(conv.InputShape...)
+		der, err = conv.PreLayer.GetActivation().Derive(der)
+		if err != nil {
+			return err
+		}
+		out := tensor.NewZeroTensor(conv.InputShape...)
+		data := out.GetData()
+		difData := dif.GetData()
+		weights := conv.Weights.GetData()
+		conv.each(func(o, i, w int) {
+			data[i] += weights[w] * difData[o]
+		})
+		err = out.MulTensor(der)
+		if err != nil {
+			return err
+		}
+		conv.PreLayer.SetDif(out)
+		err = conv.PreLayer.Dif()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) SetTrainable(t bool) {
+	conv.Trainable = t
+}
+
+func (conv *Conv) SetTraining(t bool) {
+	if conv.PreLayer != nil {
+		conv.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of the weights and bias for the last
+// input, once per step.
+func (conv *Conv) calGrads() error {
+	if conv.grads != nil {
+		return nil
+	}
+	err := conv.addActivityDif(&conv.dif, conv.Activation, conv.neta, conv.output)
+	if err != nil {
+		return err
+	}
+	weights := tensor.NewZeroTensor(conv.Weights.GetShape()...)
+	grads := weights.GetData()
+	dif := conv.dif.GetData()
+	in := conv.input.GetData()
+	conv.each(func(o, i, w int) {
+		grads[w] += dif[o] * in[i]
+	})
+	bias := conv.dif.Copy()
+	err = conv.regularize(conv.Weights, conv.Bias, weights, bias)
+	if err != nil {
+		return err
+	}
+	conv.grads = []tensor.Tensor{weights, bias}
+	return nil
+}
+
+func (conv *Conv) Fit(alpha float64, momentum float64) error {
+	if conv.cFit {
+		return nil
+	}
+	conv.cFit = true
+	if conv.Trainable {
+		err := conv.calGrads()
+		if err != nil {
+			return err
+		}
+		update(alpha, momentum,
+			[]tensor.Tensor{conv.Weights, conv.Bias},
+			[]tensor.Tensor{conv.MWeights, conv.MBias},
+			conv.grads)
+		conv.constrain(conv.Weights, conv.Bias)
+	}
+	if conv.PreLayer != nil {
+		return conv.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+func (conv *Conv) GetPenalty() (float64, error) {
+	if conv.cPenalty {
+		return 0, nil
+	}
+	penalty := conv.penalty(conv.Weights, conv.Bias, conv.output)
+	if conv.PreLayer != nil {
+		p, err := conv.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (conv *Conv) GetGradients() ([][]tensor.Tensor, error) {
+	if conv.cGrads {
+		return nil, nil
+	}
+	conv.cGrads = true
+	var grads [][]tensor.Tensor
+	if conv.Trainable {
+		err := conv.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, conv.grads)
+	}
+	if conv.PreLayer != nil {
+		pg, err := conv.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+func (conv *Conv) ResetSL() error {
+	conv.wSL = false
+	if conv.PreLayer != nil {
+		return conv.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+func (conv *Conv) GetWeights() (serialization.Weights, error) {
+	if conv.wSL {
+		return serialization.Weights{}, nil
+	}
+	conv.wSL = true
+	data := [][]float64{
+		conv.Weights.GetData(),
+		conv.Bias.GetData(),
+	}
+	w := serialization.Weights{
+		Data: data,
+	}
+	if conv.PreLayer != nil {
+		pw, e := conv.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+		w.PreWeights = []serialization.Weights{pw}
+	}
+	return w, nil
+}
+
+func (conv *Conv) SetWeights(w serialization.Weights) error {
+	if !conv.wSL {
+		conv.wSL = true
+		if w.Data != nil {
+			conv.Weights.SetData(w.Data[0])
+			conv.Bias.SetData(w.Data[1])
+		}
+		if conv.PreLayer != nil && w.PreWeights != nil {
+			if len(w.PreWeights) == 0 {
+				return errors.New("invalid preWeights len")
+			}
+			return conv.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
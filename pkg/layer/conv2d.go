@@ -4,23 +4,47 @@ import (
 	"errors"
 
 	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
 )
 
+// Conv2D convolves a [w, h, c] input with OutputShape[2] kernels of
+// KernelWidth x KernelHeight. The kernels move StrideX and StrideY positions
+// at a time, read the input every DilationX and DilationY positions and see
+// the input zero padded by PadX and PadY positions before its first column
+// and row. PadX and PadY are computed by Build unless Padding is
+// PaddingExplicit. Build sets StrideX and StrideY to Stride while they are
+// 0, and the dilations and Groups to 1.
+//
+// With Groups greater than one the input channels and the filters are split
+// in that many groups and every filter only sees the channels of its group.
+// A DepthMultiplier greater than zero makes it a depthwise convolution: one
+// group per input channel with DepthMultiplier filters each.
 type Conv2D struct {
-	Weights    tensor.Tensor
-	MWeights   tensor.Tensor
-	Bias       tensor.Tensor
-	MBias      tensor.Tensor
-	Activation activation.Activation
-	PreLayer   Layer
-
-	InputShape   []int
-	OutputShape  []int
-	KernelWidth  int
-	KernelHeight int
-	Stride       int
+	Weights           tensor.Tensor
+	MWeights          tensor.Tensor
+	Bias              tensor.Tensor
+	MBias             tensor.Tensor
+	Activation        activation.Activation
+	KernelInitializer initializer.Initializer
+	BiasInitializer   initializer.Initializer
+	PreLayer          Layer
+
+	InputShape      []int
+	OutputShape     []int
+	KernelWidth     int
+	KernelHeight    int
+	Stride          int
+	StrideX         int
+	StrideY         int
+	Padding         Padding
+	PadX            int
+	PadY            int
+	DilationX       int
+	DilationY       int
+	Groups          int
+	DepthMultiplier int
 
 	cNeta   bool
 	cOutput bool
@@ -29,6 +53,12 @@ type Conv2D struct {
 	output  tensor.Tensor
 	input   tensor.Tensor
 	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cFit    bool
+	grads   []tensor.Tensor
+	cGrads  bool
+
+	Regularization
 
 	Trainable bool
 	wSL       bool
@@ -40,37 +70,75 @@ func NewConv2D(filters, kw, kh, stride int, act activation.Activation) *Conv2D {
 		KernelWidth:  kw,
 		KernelHeight: kh,
 		Stride:       stride,
+		DilationX:    1,
+		DilationY:    1,
+		Groups:       1,
 		Activation:   act,
 		Trainable:    true,
 	}
 }
 
 func NewInConv2D(input_shape []int, filters, kw, kh, stride int, act activation.Activation) *Conv2D {
-	return &Conv2D{
-		InputShape:   input_shape,
-		OutputShape:  []int{0, 0, filters},
-		KernelWidth:  kw,
-		KernelHeight: kh,
-		Stride:       stride,
-		Activation:   act,
-		Trainable:    true,
-	}
+	conv := NewConv2D(filters, kw, kh, stride, act)
+	conv.InputShape = input_shape
+	return conv
+}
+
+// NewDepthwiseConv2D returns a depthwise Conv2D, followed by a 1x1 Conv2D it
+// makes a depthwise separable convolution.
+func NewDepthwiseConv2D(multiplier, kw, kh, stride int, act activation.Activation) *Conv2D {
+	conv := NewConv2D(0, kw, kh, stride, act)
+	conv.DepthMultiplier = multiplier
+	return conv
 }
 
 func (conv *Conv2D) GetOutShape() []int {
 	return conv.OutputShape
 }
 
-func (conv *Conv2D) calOutShape() {
-	conv.OutputShape = []int{
-		(conv.InputShape[0]-conv.KernelWidth)/conv.Stride + 1,
-		(conv.InputShape[1]-conv.KernelHeight)/conv.Stride + 1,
-		conv.OutputShape[2],
+// convSize returns the output size and the padding before the first
+// position of a convolution over in positions.
+func convSize(in, kernel, stride, dilation, pad int, padding Padding) (int, int) {
+	kernel = (kernel-1)*dilation + 1
+	switch padding {
+	case PaddingSame:
+		out := (in-1)/stride + 1
+		pad = ((out-1)*stride + kernel - in) / 2
+		if pad < 0 {
+			pad = 0
+		}
+		return out, pad
+	case PaddingExplicit:
+		return (in+2*pad-kernel)/stride + 1, pad
 	}
+	return (in-kernel)/stride + 1, 0
+}
+
+func (conv *Conv2D) calOutShape() {
+	var w, h int
+	w, conv.PadX = convSize(conv.InputShape[0], conv.KernelWidth, conv.StrideX, conv.DilationX, conv.PadX, conv.Padding)
+	h, conv.PadY = convSize(conv.InputShape[1], conv.KernelHeight, conv.StrideY, conv.DilationY, conv.PadY, conv.Padding)
+	conv.OutputShape = []int{w, h, conv.OutputShape[2]}
+}
+
+// calX returns the input column read by the kernel column i at the output
+// column p, and false when it falls in the padding.
+func (conv *Conv2D) calX(p, i int) (int, bool) {
+	x := p*conv.StrideX - conv.PadX + i*conv.DilationX
+	return x, x >= 0 && x < conv.InputShape[0]
 }
 
-func (conv *Conv2D) calIndex(p, w int) int {
-	return p*conv.Stride + w
+func (conv *Conv2D) calY(p, j int) (int, bool) {
+	y := p*conv.StrideY - conv.PadY + j*conv.DilationY
+	return y, y >= 0 && y < conv.InputShape[1]
+}
+
+// channels returns the first input channel and the number of input channels
+// seen by the filter od.
+func (conv *Conv2D) channels(od int) (int, int) {
+	in := conv.InputShape[2] / conv.Groups
+	out := conv.OutputShape[2] / conv.Groups
+	return od / out * in, in
 }
 
 func (conv *Conv2D) Build() error {
@@ -81,17 +149,53 @@ func (conv *Conv2D) Build() error {
 		conv.InputShape[2] < 1 {
 		return errors.New("invalid input shape")
 	}
+	if conv.DepthMultiplier > 0 {
+		conv.Groups = conv.InputShape[2]
+		conv.OutputShape[2] = conv.InputShape[2] * conv.DepthMultiplier
+	}
 	if conv.OutputShape[2] < 1 {
 		return errors.New("invalid output shape")
 	}
+	if conv.StrideX == 0 {
+		conv.StrideX = conv.Stride
+	}
+	if conv.StrideY == 0 {
+		conv.StrideY = conv.Stride
+	}
+	if conv.DilationX == 0 {
+		conv.DilationX = 1
+	}
+	if conv.DilationY == 0 {
+		conv.DilationY = 1
+	}
+	if conv.Groups == 0 {
+		conv.Groups = 1
+	}
+	if conv.StrideX < 1 || conv.StrideY < 1 || conv.DilationX < 1 || conv.DilationY < 1 {
+		return errors.New("invalid stride or dilation")
+	}
+	if conv.Groups < 1 || conv.InputShape[2]%conv.Groups != 0 || conv.OutputShape[2]%conv.Groups != 0 {
+		return errors.New("invalid groups")
+	}
 	if conv.Activation == nil {
 		conv.Activation = &activation.Relu{}
 	}
+	if conv.KernelInitializer == nil {
+		conv.KernelInitializer = initializer.Default
+	}
+	if conv.BiasInitializer == nil {
+		conv.BiasInitializer = initializer.Default
+	}
 	conv.calOutShape()
+	if conv.OutputShape[0] < 1 || conv.OutputShape[1] < 1 {
+		return errors.New("kernel bigger than the input")
+	}
 	conv.PreLayer = nil
-	conv.Weights = tensor.NewWeightTensor(conv.OutputShape[2], conv.InputShape[2], conv.KernelWidth, conv.KernelHeight)
-	conv.MWeights = tensor.NewZeroTensor(conv.OutputShape[2], conv.InputShape[2], conv.KernelWidth, conv.KernelHeight)
-	conv.Bias = tensor.NewWeightTensor(conv.OutputShape...)
+	channels := conv.InputShape[2] / conv.Groups
+	fanIn, fanOut := initializer.Fans(conv.OutputShape[2], channels, conv.KernelWidth, conv.KernelHeight)
+	conv.Weights = conv.KernelInitializer.Init(fanIn, fanOut, conv.OutputShape[2], channels, conv.KernelWidth, conv.KernelHeight)
+	conv.MWeights = tensor.NewZeroTensor(conv.OutputShape[2], channels, conv.KernelWidth, conv.KernelHeight)
+	conv.Bias = conv.BiasInitializer.Init(fanIn, fanOut, conv.OutputShape...)
 	conv.MBias = tensor.NewZeroTensor(conv.OutputShape...)
 	return nil
 }
@@ -123,6 +227,11 @@ func (conv *Conv2D) Reset() error {
 		conv.cNeta = false
 		conv.cOutput = false
 		conv.cDif = 0
+		conv.cFit = false
+		conv.sent = nil
+		conv.resetPenalty()
+		conv.grads = nil
+		conv.cGrads = false
 		if conv.PreLayer != nil {
 			return conv.PreLayer.Reset()
 		}
@@ -154,11 +263,20 @@ func (conv *Conv2D) Get(input tensor.Tensor) (tensor.Tensor, error) {
 
 func (conv *Conv2D) convule(od, id, x, y int) float64 {
 	var w, in float64
+	first, _ := conv.channels(od)
 	r := 0.0
 	for i := 0; i < conv.KernelWidth; i++ {
+		ix, ok := conv.calX(x, i)
+		if !ok {
+			continue
+		}
 		for j := 0; j < conv.KernelHeight; j++ {
+			iy, ok := conv.calY(y, j)
+			if !ok {
+				continue
+			}
 			w, _ = conv.Weights.Get(od, id, i, j)
-			in, _ = conv.input.Get(conv.calIndex(x, i), conv.calIndex(y, j), id)
+			in, _ = conv.input.Get(ix, iy, first+id)
 			r += w * in
 		}
 	}
@@ -179,8 +297,9 @@ func (conv *Conv2D) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
 	}
 	conv.input = input
 	out := conv.Bias.Copy()
+	channels := conv.Weights.ShapeAt(1)
 	for i := 0; i < conv.OutputShape[2]; i++ {
-		for j := 0; j < conv.InputShape[2]; j++ {
+		for j := 0; j < channels; j++ {
 			for x := 0; x < conv.OutputShape[0]; x++ {
 				for y := 0; y < conv.OutputShape[1]; y++ {
 					out.AddAt(conv.convule(i, j, x, y), x, y, i)
@@ -188,6 +307,7 @@ func (conv *Conv2D) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
 			}
 		}
 	}
+	conv.neta = out
 	return out, nil
 }
 
@@ -218,33 +338,54 @@ func (conv *Conv2D) SetDif(dif tensor.Tensor) {
 	conv.cDif++
 }
 
-func (conv *Conv2D) calDifAt(out, der tensor.Tensor, x, y, od int) {
-	d, _ := der.Get(x, y, od)
+func (conv *Conv2D) calDifAt(out, dif tensor.Tensor, x, y, od int) {
+	d, _ := dif.Get(x, y, od)
+	first, channels := conv.channels(od)
 	var w float64
-	for id := 0; id < conv.InputShape[2]; id++ {
+	for id := 0; id < channels; id++ {
 		for i := 0; i < conv.KernelWidth; i++ {
+			ix, ok := conv.calX(x, i)
+			if !ok {
+				continue
+			}
 			for j := 0; j < conv.KernelHeight; j++ {
+				iy, ok := conv.calY(y, j)
+				if !ok {
+					continue
+				}
 				w, _ = conv.Weights.Get(od, id, i, j)
-				out.AddAt(d*w, conv.calIndex(x, i), conv.calIndex(y, j), id)
+				out.AddAt(d*w, ix, iy, first+id)
 			}
 		}
 	}
 }
 
-func (conv *Conv2D) calDif(der tensor.Tensor) tensor.Tensor {
+func (conv *Conv2D) calDif(dif, der tensor.Tensor) (tensor.Tensor, error) {
 	out := tensor.NewZeroTensor(conv.InputShape...)
 	for x := 0; x < conv.OutputShape[0]; x++ {
 		for y := 0; y < conv.OutputShape[1]; y++ {
 			for od := 0; od < conv.OutputShape[2]; od++ {
-				conv.calDifAt(out, der, x, y, od)
+				conv.calDifAt(out, dif, x, y, od)
 			}
 		}
 	}
-	return out
+	err := out.MulTensor(der)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func (conv *Conv2D) Dif() error {
+	err := conv.addActivityDif(&conv.dif, conv.Activation, conv.neta, conv.output)
+	if err != nil {
+		return err
+	}
 	if conv.PreLayer != nil {
+		dif, err := unsentDif(conv.dif, &conv.sent)
+		if err != nil {
+			return err
+		}
 		der, err := conv.PreLayer.GetOne(conv.PreLayer.GetInput())
 		if err != nil {
 			return err
@@ -254,7 +395,10 @@ func (conv *Conv2D) Dif() error {
 		if err != nil {
 			return err
 		}
-		out := conv.calDif(der)
+		out, err := conv.calDif(dif, der)
+		if err != nil {
+			return err
+		}
 		conv.PreLayer.SetDif(out)
 		err = conv.PreLayer.Dif()
 		if err != nil {
@@ -268,57 +412,94 @@ func (conv *Conv2D) SetTrainable(t bool) {
 	conv.Trainable = t
 }
 
-func (conv *Conv2D) fitWeight(od, id, i, j int, alpha, momentum float64) error {
+func (conv *Conv2D) SetTraining(t bool) {
+	if conv.PreLayer != nil {
+		conv.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrad returns the gradient of the weight (od, id, i, j).
+func (conv *Conv2D) calGrad(od, id, i, j int) (float64, error) {
 	var (
 		in float64
 		d  float64
-		m  float64
 		v  float64 = 0
 		e  error
 	)
+	first, _ := conv.channels(od)
 	for x := 0; x < conv.OutputShape[0]; x++ {
+		ix, ok := conv.calX(x, i)
+		if !ok {
+			continue
+		}
 		for y := 0; y < conv.OutputShape[1]; y++ {
-			in, e = conv.input.Get(conv.calIndex(x, i), conv.calIndex(y, j), id)
+			iy, ok := conv.calY(y, j)
+			if !ok {
+				continue
+			}
+			in, e = conv.input.Get(ix, iy, first+id)
 			if e != nil {
-				return e
+				return 0, e
 			}
 			d, e = conv.dif.Get(x, y, od)
 			if e != nil {
-				return e
+				return 0, e
 			}
-			v += in * d * alpha
+			v += in * d
 		}
 	}
-	m, e = conv.MWeights.Get(od, id, i, j)
-	if e != nil {
-		return e
+	return v, nil
+}
+
+// calGrads computes the gradients of the weights and bias for the last
+// input, once per step.
+func (conv *Conv2D) calGrads() error {
+	if conv.grads != nil {
+		return nil
 	}
-	conv.Weights.AddAt(v+m*momentum, od, id, i, j)
-	conv.MWeights.Set(v, od, id, i, j)
+	err := conv.addActivityDif(&conv.dif, conv.Activation, conv.neta, conv.output)
+	if err != nil {
+		return err
+	}
+	weights := tensor.NewZeroTensor(conv.Weights.GetShape()...)
+	var g float64
+	for od := 0; od < conv.OutputShape[2]; od++ {
+		for id := 0; id < conv.Weights.ShapeAt(1); id++ {
+			for i := 0; i < conv.KernelWidth; i++ {
+				for j := 0; j < conv.KernelHeight; j++ {
+					g, err = conv.calGrad(od, id, i, j)
+					if err != nil {
+						return err
+					}
+					weights.Set(g, od, id, i, j)
+				}
+			}
+		}
+	}
+	bias := conv.dif.Copy()
+	err = conv.regularize(conv.Weights, conv.Bias, weights, bias)
+	if err != nil {
+		return err
+	}
+	conv.grads = []tensor.Tensor{weights, bias}
 	return nil
 }
 
 func (conv *Conv2D) Fit(alpha float64, momentum float64) error {
+	if conv.cFit {
+		return nil
+	}
+	conv.cFit = true
 	if conv.Trainable {
-		var e error
-		for od := 0; od < conv.OutputShape[2]; od++ {
-			for id := 0; id < conv.InputShape[2]; id++ {
-				for i := 0; i < conv.KernelWidth; i++ {
-					for j := 0; j < conv.KernelHeight; j++ {
-						e = conv.fitWeight(od, id, i, j, alpha, momentum)
-						if e != nil {
-							return e
-						}
-					}
-				}
-			}
+		err := conv.calGrads()
+		if err != nil {
+			return err
 		}
-		m := conv.dif.Copy()
-		conv.dif.MulNumber(alpha)
-		conv.MBias.MulNumber(momentum)
-		conv.dif.AddTensor(conv.MBias)
-		conv.Bias.AddTensor(conv.dif)
-		conv.MBias = m
+		update(alpha, momentum,
+			[]tensor.Tensor{conv.Weights, conv.Bias},
+			[]tensor.Tensor{conv.MWeights, conv.MBias},
+			conv.grads)
+		conv.constrain(conv.Weights, conv.Bias)
 	}
 	if conv.PreLayer != nil {
 		return conv.PreLayer.Fit(alpha, momentum)
@@ -326,6 +507,44 @@ func (conv *Conv2D) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (conv *Conv2D) GetPenalty() (float64, error) {
+	if conv.cPenalty {
+		return 0, nil
+	}
+	penalty := conv.penalty(conv.Weights, conv.Bias, conv.output)
+	if conv.PreLayer != nil {
+		p, err := conv.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (conv *Conv2D) GetGradients() ([][]tensor.Tensor, error) {
+	if conv.cGrads {
+		return nil, nil
+	}
+	conv.cGrads = true
+	var grads [][]tensor.Tensor
+	if conv.Trainable {
+		err := conv.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, conv.grads)
+	}
+	if conv.PreLayer != nil {
+		pg, err := conv.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
 func (conv *Conv2D) ResetSL() error {
 	conv.wSL = false
 	if conv.PreLayer != nil {
@@ -4,23 +4,44 @@ import (
 	"errors"
 
 	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
 )
 
+// Deconv2D is the transposed convolution of a [w, h, c] input with
+// OutputShape[2] kernels of KernelWidth x KernelHeight: every input position
+// adds its kernels to the output, StrideX and StrideY positions apart and
+// with their values DilationX and DilationY positions apart. PadX and PadY
+// positions are cropped from the borders of the output, they are computed by
+// Build unless Padding is PaddingExplicit. Build sets StrideX and StrideY
+// to Stride while they are 0, and the dilations and Groups to 1.
+//
+// Groups and DepthMultiplier split the channels as in Conv2D.
 type Deconv2D struct {
-	Weights    tensor.Tensor
-	MWeights   tensor.Tensor
-	Bias       tensor.Tensor
-	MBias      tensor.Tensor
-	Activation activation.Activation
-	PreLayer   Layer
-
-	InputShape   []int
-	OutputShape  []int
-	KernelWidth  int
-	KernelHeight int
-	Stride       int
+	Weights           tensor.Tensor
+	MWeights          tensor.Tensor
+	Bias              tensor.Tensor
+	MBias             tensor.Tensor
+	Activation        activation.Activation
+	KernelInitializer initializer.Initializer
+	BiasInitializer   initializer.Initializer
+	PreLayer          Layer
+
+	InputShape      []int
+	OutputShape     []int
+	KernelWidth     int
+	KernelHeight    int
+	Stride          int
+	StrideX         int
+	StrideY         int
+	Padding         Padding
+	PadX            int
+	PadY            int
+	DilationX       int
+	DilationY       int
+	Groups          int
+	DepthMultiplier int
 
 	cNeta   bool
 	cOutput bool
@@ -29,6 +50,12 @@ type Deconv2D struct {
 	output  tensor.Tensor
 	input   tensor.Tensor
 	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cFit    bool
+	grads   []tensor.Tensor
+	cGrads  bool
+
+	Regularization
 
 	Trainable bool
 	wSL       bool
@@ -40,37 +67,72 @@ func NewDeconv2D(filters, kw, kh, stride int, act activation.Activation) *Deconv
 		KernelWidth:  kw,
 		KernelHeight: kh,
 		Stride:       stride,
+		DilationX:    1,
+		DilationY:    1,
+		Groups:       1,
 		Activation:   act,
 		Trainable:    true,
 	}
 }
 
 func NewInDeconv2D(input_shape []int, filters, kw, kh, stride int, act activation.Activation) *Deconv2D {
-	return &Deconv2D{
-		InputShape:   input_shape,
-		OutputShape:  []int{0, 0, filters},
-		KernelWidth:  kw,
-		KernelHeight: kh,
-		Stride:       stride,
-		Activation:   act,
-		Trainable:    true,
-	}
+	deconv := NewDeconv2D(filters, kw, kh, stride, act)
+	deconv.InputShape = input_shape
+	return deconv
+}
+
+func NewDepthwiseDeconv2D(multiplier, kw, kh, stride int, act activation.Activation) *Deconv2D {
+	deconv := NewDeconv2D(0, kw, kh, stride, act)
+	deconv.DepthMultiplier = multiplier
+	return deconv
 }
 
 func (deconv *Deconv2D) GetOutShape() []int {
 	return deconv.OutputShape
 }
 
-func (deconv *Deconv2D) calOutShape() {
-	deconv.OutputShape = []int{
-		(deconv.InputShape[0]-1)*deconv.Stride + deconv.KernelWidth,
-		(deconv.InputShape[1]-1)*deconv.Stride + deconv.KernelHeight,
-		deconv.OutputShape[2],
+// deconvSize returns the output size and the padding cropped before the
+// first position of a transposed convolution over in positions.
+func deconvSize(in, kernel, stride, dilation, pad int, padding Padding) (int, int) {
+	kernel = (kernel-1)*dilation + 1
+	switch padding {
+	case PaddingSame:
+		pad = (kernel - stride) / 2
+		if pad < 0 {
+			pad = 0
+		}
+		return in * stride, pad
+	case PaddingExplicit:
+		return (in-1)*stride + kernel - 2*pad, pad
 	}
+	return (in-1)*stride + kernel, 0
+}
+
+func (deconv *Deconv2D) calOutShape() {
+	var w, h int
+	w, deconv.PadX = deconvSize(deconv.InputShape[0], deconv.KernelWidth, deconv.StrideX, deconv.DilationX, deconv.PadX, deconv.Padding)
+	h, deconv.PadY = deconvSize(deconv.InputShape[1], deconv.KernelHeight, deconv.StrideY, deconv.DilationY, deconv.PadY, deconv.Padding)
+	deconv.OutputShape = []int{w, h, deconv.OutputShape[2]}
+}
+
+// calX returns the output column written by the kernel column i from the
+// input column p, and false when it is cropped.
+func (deconv *Deconv2D) calX(p, i int) (int, bool) {
+	x := p*deconv.StrideX - deconv.PadX + i*deconv.DilationX
+	return x, x >= 0 && x < deconv.OutputShape[0]
 }
 
-func (deconv *Deconv2D) calIndex(p, d int) int {
-	return p*deconv.Stride + d
+func (deconv *Deconv2D) calY(p, j int) (int, bool) {
+	y := p*deconv.StrideY - deconv.PadY + j*deconv.DilationY
+	return y, y >= 0 && y < deconv.OutputShape[1]
+}
+
+// channels returns the first input channel and the number of input channels
+// seen by the filter od.
+func (deconv *Deconv2D) channels(od int) (int, int) {
+	in := deconv.InputShape[2] / deconv.Groups
+	out := deconv.OutputShape[2] / deconv.Groups
+	return od / out * in, in
 }
 
 func (deconv *Deconv2D) Build() error {
@@ -81,17 +143,53 @@ func (deconv *Deconv2D) Build() error {
 		deconv.InputShape[2] < 1 {
 		return errors.New("invalid input shape")
 	}
+	if deconv.DepthMultiplier > 0 {
+		deconv.Groups = deconv.InputShape[2]
+		deconv.OutputShape[2] = deconv.InputShape[2] * deconv.DepthMultiplier
+	}
 	if deconv.OutputShape[2] < 1 {
 		return errors.New("invalid output shape")
 	}
+	if deconv.StrideX == 0 {
+		deconv.StrideX = deconv.Stride
+	}
+	if deconv.StrideY == 0 {
+		deconv.StrideY = deconv.Stride
+	}
+	if deconv.DilationX == 0 {
+		deconv.DilationX = 1
+	}
+	if deconv.DilationY == 0 {
+		deconv.DilationY = 1
+	}
+	if deconv.Groups == 0 {
+		deconv.Groups = 1
+	}
+	if deconv.StrideX < 1 || deconv.StrideY < 1 || deconv.DilationX < 1 || deconv.DilationY < 1 {
+		return errors.New("invalid stride or dilation")
+	}
+	if deconv.Groups < 1 || deconv.InputShape[2]%deconv.Groups != 0 || deconv.OutputShape[2]%deconv.Groups != 0 {
+		return errors.New("invalid groups")
+	}
 	if deconv.Activation == nil {
 		deconv.Activation = &activation.Relu{}
 	}
+	if deconv.KernelInitializer == nil {
+		deconv.KernelInitializer = initializer.Default
+	}
+	if deconv.BiasInitializer == nil {
+		deconv.BiasInitializer = initializer.Default
+	}
 	deconv.calOutShape()
+	if deconv.OutputShape[0] < 1 || deconv.OutputShape[1] < 1 {
+		return errors.New("padding bigger than the output")
+	}
 	deconv.PreLayer = nil
-	deconv.Weights = tensor.NewWeightTensor(deconv.OutputShape[2], deconv.InputShape[2], deconv.KernelWidth, deconv.KernelHeight)
-	deconv.MWeights = tensor.NewZeroTensor(deconv.OutputShape[2], deconv.InputShape[2], deconv.KernelWidth, deconv.KernelHeight)
-	deconv.Bias = tensor.NewWeightTensor(deconv.OutputShape...)
+	channels := deconv.InputShape[2] / deconv.Groups
+	fanIn, fanOut := initializer.Fans(deconv.OutputShape[2], channels, deconv.KernelWidth, deconv.KernelHeight)
+	deconv.Weights = deconv.KernelInitializer.Init(fanIn, fanOut, deconv.OutputShape[2], channels, deconv.KernelWidth, deconv.KernelHeight)
+	deconv.MWeights = tensor.NewZeroTensor(deconv.OutputShape[2], channels, deconv.KernelWidth, deconv.KernelHeight)
+	deconv.Bias = deconv.BiasInitializer.Init(fanIn, fanOut, deconv.OutputShape...)
 	deconv.MBias = tensor.NewZeroTensor(deconv.OutputShape...)
 	return nil
 }
@@ -123,6 +221,11 @@ func (deconv *Deconv2D) Reset() error {
 		deconv.cNeta = false
 		deconv.cOutput = false
 		deconv.cDif = 0
+		deconv.cFit = false
+		deconv.sent = nil
+		deconv.resetPenalty()
+		deconv.grads = nil
+		deconv.cGrads = false
 		if deconv.PreLayer != nil {
 			return deconv.PreLayer.Reset()
 		}
@@ -154,11 +257,20 @@ func (deconv *Deconv2D) Get(input tensor.Tensor) (tensor.Tensor, error) {
 
 func (deconv *Deconv2D) deconvAt(out tensor.Tensor, od, id, x, y int) {
 	var w, in float64
+	first, _ := deconv.channels(od)
+	in, _ = deconv.input.Get(x, y, first+id)
 	for kx := 0; kx < deconv.KernelWidth; kx++ {
+		ox, ok := deconv.calX(x, kx)
+		if !ok {
+			continue
+		}
 		for ky := 0; ky < deconv.KernelHeight; ky++ {
-			in, _ = deconv.input.Get(x, y, id)
+			oy, ok := deconv.calY(y, ky)
+			if !ok {
+				continue
+			}
 			w, _ = deconv.Weights.Get(od, id, kx, ky)
-			out.AddAt(in*w, deconv.calIndex(x, kx), deconv.calIndex(y, ky), od)
+			out.AddAt(in*w, ox, oy, od)
 		}
 	}
 }
@@ -177,8 +289,9 @@ func (deconv *Deconv2D) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
 	}
 	deconv.input = input
 	out := deconv.Bias.Copy()
+	channels := deconv.Weights.ShapeAt(1)
 	for i := 0; i < deconv.OutputShape[2]; i++ {
-		for j := 0; j < deconv.InputShape[2]; j++ {
+		for j := 0; j < channels; j++ {
 			for x := 0; x < deconv.InputShape[0]; x++ {
 				for y := 0; y < deconv.InputShape[1]; y++ {
 					deconv.deconvAt(out, i, j, x, y)
@@ -186,6 +299,7 @@ func (deconv *Deconv2D) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
 			}
 		}
 	}
+	deconv.neta = out
 	return out, nil
 }
 
@@ -216,35 +330,57 @@ func (deconv *Deconv2D) SetDif(dif tensor.Tensor) {
 	deconv.cDif++
 }
 
-func (deconv *Deconv2D) calDifAt(der tensor.Tensor, x, y, od, id int) float64 {
-	var w float64
+func (deconv *Deconv2D) calDifAt(dif tensor.Tensor, x, y, od, id int) float64 {
+	var w, d float64
 	r := 0.0
 	for i := 0; i < deconv.KernelWidth; i++ {
+		ox, ok := deconv.calX(x, i)
+		if !ok {
+			continue
+		}
 		for j := 0; j < deconv.KernelHeight; j++ {
+			oy, ok := deconv.calY(y, j)
+			if !ok {
+				continue
+			}
 			w, _ = deconv.Weights.Get(od, id, i, j)
-			d, _ := der.Get(deconv.calIndex(x, i), deconv.calIndex(y, j), od)
+			d, _ = dif.Get(ox, oy, od)
 			r += d * w
 		}
 	}
 	return r
 }
 
-func (deconv *Deconv2D) calDif(der tensor.Tensor) tensor.Tensor {
+func (deconv *Deconv2D) calDif(dif, der tensor.Tensor) (tensor.Tensor, error) {
 	out := tensor.NewZeroTensor(deconv.InputShape...)
+	channels := deconv.Weights.ShapeAt(1)
 	for od := 0; od < deconv.OutputShape[2]; od++ {
-		for id := 0; id < deconv.InputShape[2]; id++ {
+		first, _ := deconv.channels(od)
+		for id := 0; id < channels; id++ {
 			for x := 0; x < deconv.InputShape[0]; x++ {
 				for y := 0; y < deconv.InputShape[1]; y++ {
-					out.AddAt(deconv.calDifAt(der, x, y, od, id), x, y, id)
+					out.AddAt(deconv.calDifAt(dif, x, y, od, id), x, y, first+id)
 				}
 			}
 		}
 	}
-	return out
+	err := out.MulTensor(der)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func (deconv *Deconv2D) Dif() error {
+	err := deconv.addActivityDif(&deconv.dif, deconv.Activation, deconv.neta, deconv.output)
+	if err != nil {
+		return err
+	}
 	if deconv.PreLayer != nil {
+		dif, err := unsentDif(deconv.dif, &deconv.sent)
+		if err != nil {
+			return err
+		}
 		der, err := deconv.PreLayer.GetOne(deconv.PreLayer.GetInput())
 		if err != nil {
 			return err
@@ -254,7 +390,10 @@ func (deconv *Deconv2D) Dif() error {
 		if err != nil {
 			return err
 		}
-		out := deconv.calDif(der)
+		out, err := deconv.calDif(dif, der)
+		if err != nil {
+			return err
+		}
 		deconv.PreLayer.SetDif(out)
 		err = deconv.PreLayer.Dif()
 		if err != nil {
@@ -268,57 +407,94 @@ func (deconv *Deconv2D) SetTrainable(t bool) {
 	deconv.Trainable = t
 }
 
-func (deconv *Deconv2D) fitWeight(od, id, i, j int, alpha, momentum float64) error {
+func (deconv *Deconv2D) SetTraining(t bool) {
+	if deconv.PreLayer != nil {
+		deconv.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrad returns the gradient of the weight (od, id, i, j).
+func (deconv *Deconv2D) calGrad(od, id, i, j int) (float64, error) {
 	var (
 		in float64
 		d  float64
-		m  float64
 		v  float64 = 0
 		e  error
 	)
+	first, _ := deconv.channels(od)
 	for x := 0; x < deconv.InputShape[0]; x++ {
+		ox, ok := deconv.calX(x, i)
+		if !ok {
+			continue
+		}
 		for y := 0; y < deconv.InputShape[1]; y++ {
-			in, e = deconv.input.Get(x, y, id)
+			oy, ok := deconv.calY(y, j)
+			if !ok {
+				continue
+			}
+			in, e = deconv.input.Get(x, y, first+id)
 			if e != nil {
-				return e
+				return 0, e
 			}
-			d, e = deconv.dif.Get(deconv.calIndex(x, i), deconv.calIndex(y, j), od)
+			d, e = deconv.dif.Get(ox, oy, od)
 			if e != nil {
-				return e
+				return 0, e
 			}
-			v += in * d * alpha
+			v += in * d
 		}
 	}
-	m, e = deconv.MWeights.Get(od, id, i, j)
-	if e != nil {
-		return e
+	return v, nil
+}
+
+// calGrads computes the gradients of the weights and bias for the last
+// input, once per step.
+func (deconv *Deconv2D) calGrads() error {
+	if deconv.grads != nil {
+		return nil
+	}
+	err := deconv.addActivityDif(&deconv.dif, deconv.Activation, deconv.neta, deconv.output)
+	if err != nil {
+		return err
 	}
-	deconv.Weights.AddAt(v+m*momentum, od, id, i, j)
-	deconv.MWeights.Set(v, od, id, i, j)
+	weights := tensor.NewZeroTensor(deconv.Weights.GetShape()...)
+	var g float64
+	for od := 0; od < deconv.OutputShape[2]; od++ {
+		for id := 0; id < deconv.Weights.ShapeAt(1); id++ {
+			for i := 0; i < deconv.KernelWidth; i++ {
+				for j := 0; j < deconv.KernelHeight; j++ {
+					g, err = deconv.calGrad(od, id, i, j)
+					if err != nil {
+						return err
+					}
+					weights.Set(g, od, id, i, j)
+				}
+			}
+		}
+	}
+	bias := deconv.dif.Copy()
+	err = deconv.regularize(deconv.Weights, deconv.Bias, weights, bias)
+	if err != nil {
+		return err
+	}
+	deconv.grads = []tensor.Tensor{weights, bias}
 	return nil
 }
 
 func (deconv *Deconv2D) Fit(alpha float64, momentum float64) error {
+	if deconv.cFit {
+		return nil
+	}
+	deconv.cFit = true
 	if deconv.Trainable {
-		var e error
-		for od := 0; od < deconv.OutputShape[2]; od++ {
-			for id := 0; id < deconv.InputShape[2]; id++ {
-				for i := 0; i < deconv.KernelWidth; i++ {
-					for j := 0; j < deconv.KernelHeight; j++ {
-						e = deconv.fitWeight(od, id, i, j, alpha, momentum)
-						if e != nil {
-							return e
-						}
-					}
-				}
-			}
+		err := deconv.calGrads()
+		if err != nil {
+			return err
 		}
-		m := deconv.dif.Copy()
-		deconv.dif.MulNumber(alpha)
-		deconv.MBias.MulNumber(momentum)
-		deconv.dif.AddTensor(deconv.MBias)
-		deconv.Bias.AddTensor(deconv.dif)
-		deconv.MBias = m
+		update(alpha, momentum,
+			[]tensor.Tensor{deconv.Weights, deconv.Bias},
+			[]tensor.Tensor{deconv.MWeights, deconv.MBias},
+			deconv.grads)
+		deconv.constrain(deconv.Weights, deconv.Bias)
 	}
 	if deconv.PreLayer != nil {
 		return deconv.PreLayer.Fit(alpha, momentum)
@@ -326,6 +502,44 @@ func (deconv *Deconv2D) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (deconv *Deconv2D) GetPenalty() (float64, error) {
+	if deconv.cPenalty {
+		return 0, nil
+	}
+	penalty := deconv.penalty(deconv.Weights, deconv.Bias, deconv.output)
+	if deconv.PreLayer != nil {
+		p, err := deconv.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (deconv *Deconv2D) GetGradients() ([][]tensor.Tensor, error) {
+	if deconv.cGrads {
+		return nil, nil
+	}
+	deconv.cGrads = true
+	var grads [][]tensor.Tensor
+	if deconv.Trainable {
+		err := deconv.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, deconv.grads)
+	}
+	if deconv.PreLayer != nil {
+		pg, err := deconv.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
 func (deconv *Deconv2D) ResetSL() error {
 	deconv.wSL = false
 	if deconv.PreLayer != nil {
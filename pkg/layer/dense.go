@@ -4,19 +4,24 @@ import (
 	"errors"
 
 	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
 )
 
 type Dense struct {
-	Weights    tensor.Tensor
-	MWeights   tensor.Tensor
-	Bias       tensor.Tensor
-	MBias      tensor.Tensor
-	Activation activation.Activation
-	NIn        int
-	NOut       int
-	PreLayer   Layer
+	Weights           tensor.Tensor
+	MWeights          tensor.Tensor
+	Bias              tensor.Tensor
+	MBias             tensor.Tensor
+	Activation        activation.Activation
+	KernelInitializer initializer.Initializer
+	BiasInitializer   initializer.Initializer
+	NIn               int
+	NOut              int
+	PreLayer          Layer
+
+	Regularization
 
 	Trainable bool
 
@@ -25,9 +30,13 @@ type Dense struct {
 	cOutput bool
 	output  tensor.Tensor
 
-	input tensor.Tensor
-	dif   tensor.Tensor
-	cDif  int
+	input  tensor.Tensor
+	dif    tensor.Tensor
+	sent   tensor.Tensor
+	cDif   int
+	cFit   bool
+	grads  []tensor.Tensor
+	cGrads bool
 
 	wSL bool
 }
@@ -64,9 +73,16 @@ func (dense *Dense) Build() error {
 	if dense.Activation == nil {
 		dense.Activation = &activation.Relu{}
 	}
-	dense.Weights = tensor.NewWeightTensor(dense.NOut, dense.NIn)
+	if dense.KernelInitializer == nil {
+		dense.KernelInitializer = initializer.Default
+	}
+	if dense.BiasInitializer == nil {
+		dense.BiasInitializer = initializer.Default
+	}
+	fanIn, fanOut := initializer.Fans(dense.NOut, dense.NIn)
+	dense.Weights = dense.KernelInitializer.Init(fanIn, fanOut, dense.NOut, dense.NIn)
 	dense.MWeights = tensor.NewZeroTensor(dense.NOut, dense.NIn)
-	dense.Bias = tensor.NewWeightTensor(dense.NOut)
+	dense.Bias = dense.BiasInitializer.Init(fanIn, fanOut, dense.NOut)
 	dense.MBias = tensor.NewZeroTensor(dense.NOut)
 	dense.PreLayer = nil
 	return nil
@@ -103,6 +119,11 @@ func (dense *Dense) Reset() error {
 		dense.cNeta = false
 		dense.cOutput = false
 		dense.cDif = 0
+		dense.cFit = false
+		dense.sent = nil
+		dense.resetPenalty()
+		dense.grads = nil
+		dense.cGrads = false
 		if dense.PreLayer != nil {
 			return dense.PreLayer.Reset()
 		}
@@ -183,7 +204,15 @@ func (dense *Dense) SetDif(dif tensor.Tensor) {
 }
 
 func (dense *Dense) Dif() error {
+	err := dense.addActivityDif(&dense.dif, dense.Activation, dense.neta, dense.output)
+	if err != nil {
+		return err
+	}
 	if dense.PreLayer != nil {
+		dif, err := unsentDif(dense.dif, &dense.sent)
+		if err != nil {
+			return err
+		}
 		der, err := dense.PreLayer.GetOne(dense.PreLayer.GetInput())
 		if err != nil {
 			return err
@@ -199,7 +228,7 @@ func (dense *Dense) Dif() error {
 		var d, w float64
 		for i := 0; i < dense.Weights.ShapeAt(1); i++ {
 			for j := 0; j < dense.Weights.ShapeAt(0); j++ {
-				d, _ = dense.dif.FGet(j)
+				d, _ = dif.FGet(j)
 				w, _ = dense.Weights.Get(j, i)
 				out.AddAt(d*w, i)
 			}
@@ -220,23 +249,55 @@ func (dense *Dense) SetTrainable(t bool) {
 	dense.Trainable = t
 }
 
+func (dense *Dense) SetTraining(t bool) {
+	if dense.PreLayer != nil {
+		dense.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of the weights and bias for the last
+// input, once per step.
+func (dense *Dense) calGrads() error {
+	if dense.grads != nil {
+		return nil
+	}
+	err := dense.addActivityDif(&dense.dif, dense.Activation, dense.neta, dense.output)
+	if err != nil {
+		return err
+	}
+	weights := tensor.NewZeroTensor(dense.NOut, dense.NIn)
+	w := weights.GetData()
+	in := dense.input.GetData()
+	for i, d := range dense.dif.GetData() {
+		for j, x := range in {
+			w[i*dense.NIn+j] = d * x
+		}
+	}
+	bias := dense.dif.Copy()
+	bias.Reshape(dense.NOut)
+	err = dense.regularize(dense.Weights, dense.Bias, weights, bias)
+	if err != nil {
+		return err
+	}
+	dense.grads = []tensor.Tensor{weights, bias}
+	return nil
+}
+
 func (dense *Dense) Fit(alpha float64, momentum float64) error {
+	if dense.cFit {
+		return nil
+	}
+	dense.cFit = true
 	if dense.Trainable {
-		var val, v, m float64
-		for i := 0; i < dense.Weights.ShapeAt(0); i++ {
-			m, _ = dense.dif.FGet(i)
-			val = alpha * m
-			for j := 0; j < dense.Weights.ShapeAt(1); j++ {
-				v, _ = dense.input.FGet(j)
-				v *= val
-				m, _ = dense.MWeights.Get(i, j)
-				dense.Weights.AddAt(v+m*momentum, i, j)
-				dense.MWeights.Set(v, i, j)
-			}
-			m, _ = dense.MBias.Get(i)
-			dense.Bias.AddAt(val+m*momentum, i)
-			dense.MBias.Set(val, i)
+		err := dense.calGrads()
+		if err != nil {
+			return err
 		}
+		update(alpha, momentum,
+			[]tensor.Tensor{dense.Weights, dense.Bias},
+			[]tensor.Tensor{dense.MWeights, dense.MBias},
+			dense.grads)
+		dense.constrain(dense.Weights, dense.Bias)
 	}
 	if dense.PreLayer != nil {
 		return dense.PreLayer.Fit(alpha, momentum)
@@ -244,6 +305,44 @@ func (dense *Dense) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (dense *Dense) GetPenalty() (float64, error) {
+	if dense.cPenalty {
+		return 0, nil
+	}
+	penalty := dense.penalty(dense.Weights, dense.Bias, dense.output)
+	if dense.PreLayer != nil {
+		p, err := dense.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (dense *Dense) GetGradients() ([][]tensor.Tensor, error) {
+	if dense.cGrads {
+		return nil, nil
+	}
+	dense.cGrads = true
+	var grads [][]tensor.Tensor
+	if dense.Trainable {
+		err := dense.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, dense.grads)
+	}
+	if dense.PreLayer != nil {
+		pg, err := dense.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
 func (dense *Dense) ResetSL() error {
 	dense.wSL = false
 	if dense.PreLayer != nil {
@@ -0,0 +1,277 @@
+package layer
+
+import (
+	"errors"
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Dropout multiplies its input by a random mask in training mode and lets
+// it pass untouched in inference mode. The mask keeps every value with
+// probability 1-Rate, scaled by 1/(1-Rate), or draws it from a normal
+// distribution with mean 1 when Gaussian is set. When Spatial is set the
+// mask is drawn once per channel (the last axis of a [w, h, c] input). The
+// mask is drawn from Rand, or from the shared source of package random if
+// it is nil.
+type Dropout struct {
+	PreLayer Layer
+	Shape    []int
+	Rate     float64
+	Spatial  bool
+	Gaussian bool
+	Rand     random.Source
+
+	training bool
+	mask     tensor.Tensor
+	input    tensor.Tensor
+	output   tensor.Tensor
+	dif      tensor.Tensor
+	sent     tensor.Tensor
+	cOutput  bool
+	cDif     int
+	tape     *tape
+
+	wSL bool
+}
+
+func NewDropout(rate float64) *Dropout {
+	return &Dropout{
+		Rate: rate,
+	}
+}
+
+func NewSpatialDropout2D(rate float64) *Dropout {
+	return &Dropout{
+		Rate:    rate,
+		Spatial: true,
+	}
+}
+
+func NewGaussianDropout(rate float64) *Dropout {
+	return &Dropout{
+		Rate:     rate,
+		Gaussian: true,
+	}
+}
+
+func (dropout *Dropout) GetOutShape() []int {
+	return dropout.Shape
+}
+
+func (dropout *Dropout) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (dropout *Dropout) SetPrelayer(lay Layer) error {
+	if dropout.PreLayer != nil && lay != nil && !tensor.CompareShape(dropout.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	dropout.PreLayer = lay
+	return nil
+}
+
+func (dropout *Dropout) Connect(p Layer) error {
+	if dropout.Rate < 0 || dropout.Rate >= 1 {
+		return errors.New("invalid rate")
+	}
+	shape := p.GetOutShape()
+	if dropout.Spatial && len(shape) != 3 {
+		return errors.New("invalid input shape")
+	}
+	dropout.PreLayer = p
+	dropout.Shape = shape
+	return nil
+}
+
+func (dropout *Dropout) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (dropout *Dropout) Reset() error {
+	if dropout.cOutput || dropout.cDif != 0 {
+		dropout.cOutput = false
+		dropout.cDif = 0
+		dropout.sent = nil
+		return dropout.PreLayer.Reset()
+	}
+	return nil
+}
+
+func (dropout *Dropout) FullReset() error {
+	return dropout.Reset()
+}
+
+func (dropout *Dropout) GetInput() tensor.Tensor {
+	return dropout.input
+}
+
+func (dropout *Dropout) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if dropout.cOutput {
+		return dropout.output, nil
+	}
+	var e error
+	input, e = dropout.PreLayer.Output(input)
+	if e != nil {
+		return nil, e
+	}
+	return dropout.GetOne(input)
+}
+
+func (dropout *Dropout) sample() float64 {
+	src := random.Or(dropout.Rand)
+	if dropout.Gaussian {
+		return 1 + src.NormFloat64()*math.Sqrt(dropout.Rate/(1-dropout.Rate))
+	}
+	if src.Float64() < dropout.Rate {
+		return 0
+	}
+	return 1 / (1 - dropout.Rate)
+}
+
+func (dropout *Dropout) newMask() tensor.Tensor {
+	mask := tensor.NewZeroTensor(dropout.Shape...)
+	data := mask.GetData()
+	if dropout.Spatial {
+		channels := dropout.Shape[2]
+		values := make([]float64, channels)
+		for i := range values {
+			values[i] = dropout.sample()
+		}
+		for i := range data {
+			data[i] = values[i%channels]
+		}
+	} else {
+		for i := range data {
+			data[i] = dropout.sample()
+		}
+	}
+	return mask
+}
+
+func (dropout *Dropout) setTape(tp *tape) {
+	dropout.tape = tp
+}
+
+func (dropout *Dropout) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if dropout.cOutput {
+		return dropout.output, nil
+	}
+	if input.Size() != tensor.MulIndex(dropout.Shape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	dropout.input = input
+	dropout.output = input.Copy()
+	dropout.output.Reshape(dropout.Shape...)
+	dropout.mask = nil
+	if dropout.training && dropout.Rate > 0 {
+		dropout.mask = dropout.tape.keep(func() []tensor.Tensor {
+			return []tensor.Tensor{dropout.newMask()}
+		})[0]
+		e := dropout.output.MulTensor(dropout.mask)
+		if e != nil {
+			return nil, e
+		}
+	}
+	dropout.cOutput = true
+	return dropout.output, nil
+}
+
+func (dropout *Dropout) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return dropout.Get(input)
+}
+
+func (dropout *Dropout) SetDif(dif tensor.Tensor) {
+	dif.Reshape(dropout.Shape...)
+	if dropout.cDif == 0 {
+		dropout.dif = dif
+	} else {
+		dropout.dif.AddTensor(dif)
+	}
+	dropout.cDif++
+}
+
+func (dropout *Dropout) Dif() error {
+	der, e := dropout.PreLayer.GetOne(dropout.PreLayer.GetInput())
+	if e != nil {
+		return e
+	}
+	der.Reshape(dropout.Shape...)
+	der, e = dropout.PreLayer.GetActivation().Derive(der)
+	if e != nil {
+		return e
+	}
+	out, e := unsentDif(dropout.dif, &dropout.sent)
+	if e != nil {
+		return e
+	}
+	if dropout.mask != nil {
+		e = out.MulTensor(dropout.mask)
+		if e != nil {
+			return e
+		}
+	}
+	e = out.MulTensor(der)
+	if e != nil {
+		return e
+	}
+	dropout.PreLayer.SetDif(out)
+	return dropout.PreLayer.Dif()
+}
+
+func (dropout *Dropout) SetTrainable(bool) {}
+
+func (dropout *Dropout) SetTraining(t bool) {
+	dropout.training = t
+	dropout.PreLayer.SetTraining(t)
+}
+
+func (dropout *Dropout) Fit(alpha, momentum float64) error {
+	return dropout.PreLayer.Fit(alpha, momentum)
+}
+
+func (dropout *Dropout) GetPenalty() (float64, error) {
+	return dropout.PreLayer.GetPenalty()
+}
+
+func (dropout *Dropout) GetGradients() ([][]tensor.Tensor, error) {
+	return dropout.PreLayer.GetGradients()
+}
+
+func (dropout *Dropout) ResetSL() error {
+	dropout.wSL = false
+	return dropout.PreLayer.ResetSL()
+}
+
+func (dropout *Dropout) GetWeights() (serialization.Weights, error) {
+	if dropout.wSL {
+		return serialization.Weights{}, nil
+	}
+	dropout.wSL = true
+
+	pw, e := dropout.PreLayer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw},
+	}, nil
+}
+
+func (dropout *Dropout) SetWeights(w serialization.Weights) error {
+	if dropout.wSL {
+		return nil
+	}
+	dropout.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) == 0 {
+			return errors.New("invalid preWeights len")
+		}
+		return dropout.PreLayer.SetWeights(w.PreWeights[0])
+	}
+	return nil
+}
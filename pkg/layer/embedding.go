@@ -0,0 +1,383 @@
+package layer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Embedding takes a sequence of Length token indices and returns the rows
+// of Table ([Vocab, Dim]) for them, so its output shape is [Length, Dim].
+// Its gradient is sparse, it only has the rows of the tokens of the last
+// input, and only those rows are updated by Fit.
+type Embedding struct {
+	Table       tensor.Tensor
+	MTable      tensor.Tensor
+	Vocab       int
+	Dim         int
+	Length      int
+	PreLayer    Layer
+	Initializer initializer.Initializer
+
+	Trainable bool
+
+	cOutput bool
+	output  tensor.Tensor
+	input   tensor.Tensor
+	indices []int
+	rows    []int
+	dif     tensor.Tensor
+	cFit    bool
+	grads   []tensor.Tensor
+	cGrads  bool
+	cDif    int
+
+	wSL bool
+}
+
+func NewEmbedding(vocab, dim int) *Embedding {
+	return &Embedding{
+		Vocab:     vocab,
+		Dim:       dim,
+		Trainable: true,
+	}
+}
+
+func NewInEmbedding(length, vocab, dim int) *Embedding {
+	return &Embedding{
+		Vocab:     vocab,
+		Dim:       dim,
+		Length:    length,
+		Trainable: true,
+	}
+}
+
+func (emb *Embedding) GetOutShape() []int {
+	return []int{emb.Length, emb.Dim}
+}
+
+func (emb *Embedding) Build() error {
+	if emb.Length < 1 {
+		return errors.New("invalid input size")
+	}
+	if emb.Vocab < 1 {
+		return errors.New("invalid vocabulary size")
+	}
+	if emb.Dim < 1 {
+		return errors.New("invalid embedding size")
+	}
+	if emb.Initializer == nil {
+		emb.Initializer = initializer.Default
+	}
+	emb.Table = emb.Initializer.Init(emb.Vocab, emb.Dim, emb.Vocab, emb.Dim)
+	emb.MTable = tensor.NewZeroTensor(emb.Vocab, emb.Dim)
+	emb.PreLayer = nil
+	return nil
+}
+
+func (emb *Embedding) SetPrelayer(lay Layer) error {
+	if emb.PreLayer != nil && lay != nil && !tensor.CompareShape(emb.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	emb.PreLayer = lay
+	return nil
+}
+
+func (emb *Embedding) Connect(preLayer Layer) error {
+	emb.Length = tensor.MulIndex(preLayer.GetOutShape(), -1)
+	err := emb.Build()
+	if err != nil {
+		return err
+	}
+	emb.PreLayer = preLayer
+	return nil
+}
+
+func (emb *Embedding) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (emb *Embedding) Reset() error {
+	if emb.cOutput || emb.cDif != 0 {
+		emb.cOutput = false
+		emb.cDif = 0
+		emb.cFit = false
+		emb.grads = nil
+		emb.cGrads = false
+		if emb.PreLayer != nil {
+			return emb.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (emb *Embedding) FullReset() error {
+	return emb.Reset()
+}
+
+func (emb *Embedding) GetInput() tensor.Tensor {
+	return emb.input
+}
+
+func (emb *Embedding) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if emb.cOutput {
+		return emb.output, nil
+	}
+	if emb.PreLayer != nil {
+		var err error
+		input, err = emb.PreLayer.Output(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return emb.GetOne(input)
+}
+
+func (emb *Embedding) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if emb.cOutput {
+		return emb.output, nil
+	}
+	if input.Size() != emb.Length {
+		return nil, errors.New("incompatible input shape")
+	}
+	emb.input = input
+	emb.indices = make([]int, emb.Length)
+	out := tensor.NewZeroTensor(emb.Length, emb.Dim)
+	data := out.GetData()
+	table := emb.Table.GetData()
+	for i, x := range input.GetData() {
+		index := int(math.Round(x))
+		if index < 0 || index >= emb.Vocab {
+			return nil, errors.New("token index out of range")
+		}
+		emb.indices[i] = index
+		copy(data[i*emb.Dim:(i+1)*emb.Dim], table[index*emb.Dim:(index+1)*emb.Dim])
+	}
+	emb.output = out
+	emb.cOutput = true
+	return out, nil
+}
+
+func (emb *Embedding) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return emb.Get(input)
+}
+
+func (emb *Embedding) SetDif(dif tensor.Tensor) {
+	dif.Reshape(emb.Length, emb.Dim)
+	if emb.cDif == 0 {
+		emb.dif = dif
+	} else {
+		emb.dif.AddTensor(dif)
+	}
+	emb.cDif++
+}
+
+// Dif sends a zero gradient to the prelayer, the token indices are not
+// differentiable.
+func (emb *Embedding) Dif() error {
+	if emb.PreLayer != nil {
+		emb.PreLayer.SetDif(tensor.NewZeroTensor(emb.PreLayer.GetOutShape()...))
+		return emb.PreLayer.Dif()
+	}
+	return nil
+}
+
+func (emb *Embedding) SetTrainable(t bool) {
+	emb.Trainable = t
+}
+
+func (emb *Embedding) SetTraining(t bool) {
+	if emb.PreLayer != nil {
+		emb.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradient of Table for the last input, once per
+// step. It is a [len(rows), Dim] tensor with the gradient of the row of
+// Table of every distinct token of the input, in the order of rows.
+func (emb *Embedding) calGrads() []tensor.Tensor {
+	if emb.grads != nil {
+		return emb.grads
+	}
+	emb.rows = nil
+	position := map[int]int{}
+	for _, index := range emb.indices {
+		if _, ok := position[index]; !ok {
+			position[index] = len(emb.rows)
+			emb.rows = append(emb.rows, index)
+		}
+	}
+	rows := tensor.NewZeroTensor(len(emb.rows), emb.Dim)
+	grads := rows.GetData()
+	dif := emb.dif.GetData()
+	for i, index := range emb.indices {
+		r := position[index]
+		for j := 0; j < emb.Dim; j++ {
+			grads[r*emb.Dim+j] += dif[i*emb.Dim+j]
+		}
+	}
+	emb.grads = []tensor.Tensor{rows}
+	return emb.grads
+}
+
+func (emb *Embedding) Fit(alpha float64, momentum float64) error {
+	if emb.cFit {
+		return nil
+	}
+	emb.cFit = true
+	if emb.Trainable {
+		grads := emb.calGrads()[0].GetData()
+		table := emb.Table.GetData()
+		moments := emb.MTable.GetData()
+		var v float64
+		var k int
+		for r, index := range emb.rows {
+			for j := 0; j < emb.Dim; j++ {
+				k = index*emb.Dim + j
+				v = alpha * grads[r*emb.Dim+j]
+				table[k] += v + moments[k]*momentum
+				moments[k] = v
+			}
+		}
+	}
+	if emb.PreLayer != nil {
+		return emb.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+func (emb *Embedding) GetPenalty() (float64, error) {
+	if emb.PreLayer != nil {
+		return emb.PreLayer.GetPenalty()
+	}
+	return 0, nil
+}
+
+func (emb *Embedding) GetGradients() ([][]tensor.Tensor, error) {
+	if emb.cGrads {
+		return nil, nil
+	}
+	emb.cGrads = true
+	var grads [][]tensor.Tensor
+	if emb.Trainable {
+		grads = append(grads, emb.calGrads())
+	}
+	if emb.PreLayer != nil {
+		pg, err := emb.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+// LoadVectors reads pretrained vectors in the GloVe text format, one token
+// per line followed by its Dim values, and copies the ones of the tokens in
+// vocab into their rows of Table. It returns how many rows were loaded.
+func (emb *Embedding) LoadVectors(r io.Reader, vocab map[string]int) (int, error) {
+	if emb.Table == nil {
+		return 0, errors.New("the layer is not built")
+	}
+	loaded := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		index, ok := vocab[fields[0]]
+		if !ok {
+			continue
+		}
+		if index < 0 || index >= emb.Vocab {
+			return loaded, fmt.Errorf("token %q index out of range", fields[0])
+		}
+		if len(fields)-1 != emb.Dim {
+			return loaded, fmt.Errorf("line %d: expected %d values, got %d", line, emb.Dim, len(fields)-1)
+		}
+		for j, field := range fields[1:] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return loaded, fmt.Errorf("line %d: %w", line, err)
+			}
+			emb.Table.Set(v, index, j)
+		}
+		loaded++
+	}
+	return loaded, scanner.Err()
+}
+
+// LoadGloVe loads the vectors of a GloVe text file with LoadVectors.
+func (emb *Embedding) LoadGloVe(path string, vocab map[string]int) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return emb.LoadVectors(file, vocab)
+}
+
+func (emb *Embedding) ResetSL() error {
+	emb.wSL = false
+	if emb.PreLayer != nil {
+		return emb.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+func (emb *Embedding) GetWeights() (serialization.Weights, error) {
+	if emb.wSL {
+		return serialization.Weights{}, nil
+	}
+	emb.wSL = true
+	data := [][]float64{
+		emb.Table.GetData(),
+	}
+
+	w := serialization.Weights{
+		Data: data,
+	}
+
+	if emb.PreLayer != nil {
+		pw, e := emb.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+		w.PreWeights = []serialization.Weights{pw}
+	}
+	return w, nil
+}
+
+func (emb *Embedding) SetWeights(w serialization.Weights) error {
+	if !emb.wSL {
+		emb.wSL = true
+		if w.Data != nil {
+			if len(w.Data) < 1 {
+				return errors.New("invalid weights len")
+			}
+			emb.Table.SetData(w.Data[0])
+		}
+
+		if emb.PreLayer != nil && w.PreWeights != nil {
+			if len(w.PreWeights) == 0 {
+				return errors.New("invalid preWeights len")
+			}
+			return emb.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
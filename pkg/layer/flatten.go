@@ -114,10 +114,22 @@ func (flatten *Flatten) Dif() error {
 
 func (flatten *Flatten) SetTrainable(bool) {}
 
+func (flatten *Flatten) SetTraining(t bool) {
+	flatten.PreLayer.SetTraining(t)
+}
+
 func (flatten *Flatten) Fit(alpha, momentum float64) error {
 	return flatten.PreLayer.Fit(alpha, momentum)
 }
 
+func (flatten *Flatten) GetPenalty() (float64, error) {
+	return flatten.PreLayer.GetPenalty()
+}
+
+func (flatten *Flatten) GetGradients() ([][]tensor.Tensor, error) {
+	return flatten.PreLayer.GetGradients()
+}
+
 func (flatten *Flatten) ResetSL() error {
 	flatten.wSL = false
 	return flatten.PreLayer.ResetSL()
@@ -0,0 +1,240 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Forward computes the output of a Func layer from its input.
+type Forward func(input tensor.Tensor) (tensor.Tensor, error)
+
+// Backward computes the gradient of the input of a Func layer from its input
+// and the gradient of its output.
+type Backward func(input, dif tensor.Tensor) (tensor.Tensor, error)
+
+// Func is a layer without weights that applies user functions. If Shape is
+// not set, Connect gets it by calling Forward with a zero input. Without a
+// Backward the layer can only be used for inference.
+type Func struct {
+	PreLayer Layer
+	Forward  Forward
+	Backward Backward
+	InShape  []int
+	Shape    []int
+
+	input   tensor.Tensor
+	cOutput bool
+	output  tensor.Tensor
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cDif    int
+
+	wSL bool
+}
+
+func NewFunc(forward Forward, backward Backward) *Func {
+	return &Func{
+		Forward:  forward,
+		Backward: backward,
+	}
+}
+
+func NewShapedFunc(shape []int, forward Forward, backward Backward) *Func {
+	return &Func{
+		Forward:  forward,
+		Backward: backward,
+		Shape:    shape,
+	}
+}
+
+func (f *Func) GetOutShape() []int {
+	return f.Shape
+}
+
+func (f *Func) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (f *Func) SetPrelayer(lay Layer) error {
+	if f.PreLayer != nil && lay != nil && !tensor.CompareShape(f.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	f.PreLayer = lay
+	return nil
+}
+
+func (f *Func) Connect(p Layer) error {
+	if f.Forward == nil {
+		return errors.New("no forward function")
+	}
+	f.InShape = p.GetOutShape()
+	if f.Shape == nil {
+		out, e := f.Forward(tensor.NewZeroTensor(f.InShape...))
+		if e != nil {
+			return e
+		}
+		f.Shape = out.GetShape()
+	}
+	f.PreLayer = p
+	return nil
+}
+
+func (f *Func) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (f *Func) Reset() error {
+	if f.cOutput || f.cDif != 0 {
+		f.cOutput = false
+		f.cDif = 0
+		f.sent = nil
+		return f.PreLayer.Reset()
+	}
+	return nil
+}
+
+func (f *Func) FullReset() error {
+	return f.Reset()
+}
+
+func (f *Func) GetInput() tensor.Tensor {
+	return f.input
+}
+
+func (f *Func) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if f.cOutput {
+		return f.output, nil
+	}
+	var e error
+	input, e = f.PreLayer.Output(input)
+	if e != nil {
+		return nil, e
+	}
+	return f.GetOne(input)
+}
+
+func (f *Func) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if f.cOutput {
+		return f.output, nil
+	}
+	if input.Size() != tensor.MulIndex(f.InShape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	f.input = input
+	input = input.Copy()
+	input.Reshape(f.InShape...)
+	out, e := f.Forward(input)
+	if e != nil {
+		return nil, e
+	}
+	if out.Size() != tensor.MulIndex(f.Shape, -1) {
+		return nil, errors.New("incompatible forward output shape")
+	}
+	out.Reshape(f.Shape...)
+	f.output = out
+	f.cOutput = true
+	return out, nil
+}
+
+func (f *Func) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return f.Get(input)
+}
+
+func (f *Func) SetDif(dif tensor.Tensor) {
+	dif.Reshape(f.Shape...)
+	if f.cDif == 0 {
+		f.dif = dif
+	} else {
+		f.dif.AddTensor(dif)
+	}
+	f.cDif++
+}
+
+func (f *Func) Dif() error {
+	if f.Backward == nil {
+		return errors.New("no backward function")
+	}
+	der, e := f.PreLayer.GetOne(f.PreLayer.GetInput())
+	if e != nil {
+		return e
+	}
+	der.Reshape(f.InShape...)
+	der, e = f.PreLayer.GetActivation().Derive(der)
+	if e != nil {
+		return e
+	}
+	dif, e := unsentDif(f.dif, &f.sent)
+	if e != nil {
+		return e
+	}
+	input := f.input.Copy()
+	input.Reshape(f.InShape...)
+	out, e := f.Backward(input, dif)
+	if e != nil {
+		return e
+	}
+	if out.Size() != tensor.MulIndex(f.InShape, -1) {
+		return errors.New("incompatible backward output shape")
+	}
+	out.Reshape(f.InShape...)
+	e = out.MulTensor(der)
+	if e != nil {
+		return e
+	}
+	f.PreLayer.SetDif(out)
+	return f.PreLayer.Dif()
+}
+
+func (f *Func) SetTrainable(bool) {}
+
+func (f *Func) SetTraining(t bool) {
+	f.PreLayer.SetTraining(t)
+}
+
+func (f *Func) Fit(alpha, momentum float64) error {
+	return f.PreLayer.Fit(alpha, momentum)
+}
+
+func (f *Func) GetPenalty() (float64, error) {
+	return f.PreLayer.GetPenalty()
+}
+
+func (f *Func) GetGradients() ([][]tensor.Tensor, error) {
+	return f.PreLayer.GetGradients()
+}
+
+func (f *Func) ResetSL() error {
+	f.wSL = false
+	return f.PreLayer.ResetSL()
+}
+
+func (f *Func) GetWeights() (serialization.Weights, error) {
+	if f.wSL {
+		return serialization.Weights{}, nil
+	}
+	f.wSL = true
+	pw, e := f.PreLayer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw},
+	}, nil
+}
+
+func (f *Func) SetWeights(w serialization.Weights) error {
+	if f.wSL {
+		return nil
+	}
+	f.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) == 0 {
+			return errors.New("invalid preWeights len")
+		}
+		return f.PreLayer.SetWeights(w.PreWeights[0])
+	}
+	return nil
+}
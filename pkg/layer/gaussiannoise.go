@@ -0,0 +1,220 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// GaussianNoise adds normal noise with a standard deviation of Stddev to its
+// input in training mode and lets it pass untouched in inference mode. The
+// noise is drawn from Rand, or from the shared source of package random if
+// it is nil.
+type GaussianNoise struct {
+	PreLayer Layer
+	Shape    []int
+	Stddev   float64
+	Rand     random.Source
+
+	training bool
+	input    tensor.Tensor
+	output   tensor.Tensor
+	dif      tensor.Tensor
+	sent     tensor.Tensor
+	cOutput  bool
+	cDif     int
+	tape     *tape
+
+	wSL bool
+}
+
+func NewGaussianNoise(stddev float64) *GaussianNoise {
+	return &GaussianNoise{
+		Stddev: stddev,
+	}
+}
+
+func (noise *GaussianNoise) GetOutShape() []int {
+	return noise.Shape
+}
+
+func (noise *GaussianNoise) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (noise *GaussianNoise) SetPrelayer(lay Layer) error {
+	if noise.PreLayer != nil && lay != nil && !tensor.CompareShape(noise.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	noise.PreLayer = lay
+	return nil
+}
+
+func (noise *GaussianNoise) Connect(p Layer) error {
+	if noise.Stddev < 0 {
+		return errors.New("invalid stddev")
+	}
+	noise.PreLayer = p
+	noise.Shape = p.GetOutShape()
+	return nil
+}
+
+func (noise *GaussianNoise) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (noise *GaussianNoise) Reset() error {
+	if noise.cOutput || noise.cDif != 0 {
+		noise.cOutput = false
+		noise.cDif = 0
+		noise.sent = nil
+		return noise.PreLayer.Reset()
+	}
+	return nil
+}
+
+func (noise *GaussianNoise) FullReset() error {
+	return noise.Reset()
+}
+
+func (noise *GaussianNoise) GetInput() tensor.Tensor {
+	return noise.input
+}
+
+func (noise *GaussianNoise) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if noise.cOutput {
+		return noise.output, nil
+	}
+	var e error
+	input, e = noise.PreLayer.Output(input)
+	if e != nil {
+		return nil, e
+	}
+	return noise.GetOne(input)
+}
+
+func (noise *GaussianNoise) setTape(tp *tape) {
+	noise.tape = tp
+}
+
+func (noise *GaussianNoise) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if noise.cOutput {
+		return noise.output, nil
+	}
+	if input.Size() != tensor.MulIndex(noise.Shape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	noise.input = input
+	noise.output = input.Copy()
+	noise.output.Reshape(noise.Shape...)
+	if noise.training && noise.Stddev > 0 {
+		n := noise.tape.keep(func() []tensor.Tensor {
+			n := tensor.NewZeroTensor(noise.Shape...)
+			data := n.GetData()
+			src := random.Or(noise.Rand)
+			for i := range data {
+				data[i] = src.NormFloat64() * noise.Stddev
+			}
+			return []tensor.Tensor{n}
+		})[0]
+		e := noise.output.AddTensor(n)
+		if e != nil {
+			return nil, e
+		}
+	}
+	noise.cOutput = true
+	return noise.output, nil
+}
+
+func (noise *GaussianNoise) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return noise.Get(input)
+}
+
+func (noise *GaussianNoise) SetDif(dif tensor.Tensor) {
+	dif.Reshape(noise.Shape...)
+	if noise.cDif == 0 {
+		noise.dif = dif
+	} else {
+		noise.dif.AddTensor(dif)
+	}
+	noise.cDif++
+}
+
+func (noise *GaussianNoise) Dif() error {
+	der, e := noise.PreLayer.GetOne(noise.PreLayer.GetInput())
+	if e != nil {
+		return e
+	}
+	der.Reshape(noise.Shape...)
+	der, e = noise.PreLayer.GetActivation().Derive(der)
+	if e != nil {
+		return e
+	}
+	out, e := unsentDif(noise.dif, &noise.sent)
+	if e != nil {
+		return e
+	}
+	e = out.MulTensor(der)
+	if e != nil {
+		return e
+	}
+	noise.PreLayer.SetDif(out)
+	return noise.PreLayer.Dif()
+}
+
+func (noise *GaussianNoise) SetTrainable(bool) {}
+
+func (noise *GaussianNoise) SetTraining(t bool) {
+	noise.training = t
+	noise.PreLayer.SetTraining(t)
+}
+
+func (noise *GaussianNoise) Fit(alpha, momentum float64) error {
+	return noise.PreLayer.Fit(alpha, momentum)
+}
+
+func (noise *GaussianNoise) GetPenalty() (float64, error) {
+	return noise.PreLayer.GetPenalty()
+}
+
+func (noise *GaussianNoise) GetGradients() ([][]tensor.Tensor, error) {
+	return noise.PreLayer.GetGradients()
+}
+
+func (noise *GaussianNoise) ResetSL() error {
+	noise.wSL = false
+	return noise.PreLayer.ResetSL()
+}
+
+func (noise *GaussianNoise) GetWeights() (serialization.Weights, error) {
+	if noise.wSL {
+		return serialization.Weights{}, nil
+	}
+	noise.wSL = true
+
+	pw, e := noise.PreLayer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw},
+	}, nil
+}
+
+func (noise *GaussianNoise) SetWeights(w serialization.Weights) error {
+	if noise.wSL {
+		return nil
+	}
+	noise.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) == 0 {
+			return errors.New("invalid preWeights len")
+		}
+		return noise.PreLayer.SetWeights(w.PreWeights[0])
+	}
+	return nil
+}
@@ -84,10 +84,20 @@ func (inlay *Input) SetTrainable(bool) {
 
 }
 
+func (inlay *Input) SetTraining(bool) {}
+
 func (inlay *Input) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (inlay *Input) GetPenalty() (float64, error) {
+	return 0, nil
+}
+
+func (inlay *Input) GetGradients() ([][]tensor.Tensor, error) {
+	return nil, nil
+}
+
 func (inlay *Input) ResetSL() error {
 	return nil
 }
@@ -15,6 +15,7 @@ type Join struct {
 	output  tensor.Tensor
 	der     tensor.Tensor
 	dif     tensor.Tensor
+	sent    tensor.Tensor
 	cOutput bool
 	cDer    bool
 	cDif    int
@@ -73,6 +74,7 @@ func (join *Join) Reset() error {
 	if join.cOutput || join.cDif != 0 {
 		join.cOutput = false
 		join.cDif = 0
+		join.sent = nil
 		var e error
 		for _, l := range join.PreLayers {
 			e = l.Reset()
@@ -146,17 +148,21 @@ func (join *Join) SetDif(dif tensor.Tensor) {
 }
 
 func (join *Join) Dif() error {
+	dif, e := unsentDif(join.dif, &join.sent)
+	if e != nil {
+		return e
+	}
 	var t tensor.Tensor
-	var e error
 	st := 0
 	if len(join.Shape) == 1 {
 		for _, l := range join.PreLayers {
 			size := l.GetOutShape()[0]
-			data := join.dif.GetData()[st : st+size]
+			data := dif.GetData()[st : st+size]
+			st += size
 			l.SetDif(tensor.NewTensor(data, size))
 			e = l.Dif()
 			if e != nil {
-				return nil
+				return e
 			}
 		}
 	} else {
@@ -164,7 +170,7 @@ func (join *Join) Dif() error {
 			ntens := l.GetOutShape()[0]
 			tens := make([]tensor.Tensor, ntens)
 			for i := 0; i < ntens; i++ {
-				t, e = join.dif.GetSubTensor(st)
+				t, e = dif.GetSubTensor(st)
 				st++
 				if e != nil {
 					return e
@@ -179,7 +185,7 @@ func (join *Join) Dif() error {
 			l.SetDif(ten)
 			e = l.Dif()
 			if e != nil {
-				return nil
+				return e
 			}
 		}
 	}
@@ -188,6 +194,12 @@ func (join *Join) Dif() error {
 
 func (join *Join) SetTrainable(bool) {}
 
+func (join *Join) SetTraining(t bool) {
+	for _, l := range join.PreLayers {
+		l.SetTraining(t)
+	}
+}
+
 func (join *Join) Fit(alpha, momentum float64) error {
 	var e error
 	for _, l := range join.PreLayers {
@@ -199,6 +211,30 @@ func (join *Join) Fit(alpha, momentum float64) error {
 	return nil
 }
 
+func (join *Join) GetPenalty() (float64, error) {
+	penalty := 0.0
+	for _, l := range join.PreLayers {
+		p, e := l.GetPenalty()
+		if e != nil {
+			return 0, e
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (join *Join) GetGradients() ([][]tensor.Tensor, error) {
+	var grads [][]tensor.Tensor
+	for _, l := range join.PreLayers {
+		g, e := l.GetGradients()
+		if e != nil {
+			return nil, e
+		}
+		grads = append(grads, g...)
+	}
+	return grads, nil
+}
+
 func (join *Join) ResetSL() error {
 	join.wSL = false
 	var e error
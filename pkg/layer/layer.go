@@ -28,9 +28,48 @@ type Layer interface {
 	Dif() error
 
 	SetTrainable(bool)
+	SetTraining(bool)
 	Fit(float64, float64) error
+	// GetPenalty returns the penalty of the regularizers of the layer and
+	// its prelayers for the last output.
+	GetPenalty() (float64, error)
+	// GetGradients returns the gradients that Fit is going to apply to the
+	// weights of the layer and its prelayers, one group per layer. They can
+	// be changed in place before calling Fit.
+	GetGradients() ([][]tensor.Tensor, error)
 
 	ResetSL() error
 	GetWeights() (serialization.Weights, error)
 	SetWeights(serialization.Weights) error
 }
+
+// unsentDif returns the part of dif that was not sent to the prelayers yet
+// and marks it as sent. A layer used by several layers receives their
+// gradients one at a time and every one of them calls its Dif, so every call
+// only sends what arrived since the last one.
+func unsentDif(dif tensor.Tensor, sent *tensor.Tensor) (tensor.Tensor, error) {
+	out := dif.Copy()
+	if *sent != nil {
+		err := out.SubTensor(*sent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	*sent = dif.Copy()
+	return out, nil
+}
+
+// update moves every weight by its gradient times alpha plus its momentum
+// times momentum and keeps the move as the new momentum.
+func update(alpha, momentum float64, weights, moments, grads []tensor.Tensor) {
+	for i, w := range weights {
+		data := w.GetData()
+		m := moments[i].GetData()
+		var v float64
+		for j, g := range grads[i].GetData() {
+			v = alpha * g
+			data[j] += v + m[j]*momentum
+			m[j] = v
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package layer
+
+import (
+	"errors"
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// LayerNorm normalizes every input with its own mean and variance, taken
+// over all its values, then scales and shifts every feature (the last axis
+// of the input) with the learned Gamma and Beta.
+type LayerNorm struct {
+	Gamma    tensor.Tensor
+	MGamma   tensor.Tensor
+	Beta     tensor.Tensor
+	MBeta    tensor.Tensor
+	PreLayer Layer
+
+	Shape    []int
+	Features int
+	Epsilon  float64
+
+	Trainable bool
+
+	cOutput bool
+	output  tensor.Tensor
+	input   tensor.Tensor
+	norm    tensor.Tensor
+	scale   float64
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cFit    bool
+	grads   []tensor.Tensor
+	cGrads  bool
+	cDif    int
+
+	wSL bool
+}
+
+func NewLayerNorm() *LayerNorm {
+	return &LayerNorm{
+		Epsilon:   1e-3,
+		Trainable: true,
+	}
+}
+
+func (ln *LayerNorm) GetOutShape() []int {
+	return ln.Shape
+}
+
+func (ln *LayerNorm) Build() error {
+	if ln.Shape == nil || len(ln.Shape) < 1 {
+		return errors.New("invalid input shape")
+	}
+	ln.Features = ln.Shape[len(ln.Shape)-1]
+	if ln.Features < 1 {
+		return errors.New("invalid input shape")
+	}
+	ln.Gamma = tensor.NewOneTensor(ln.Features)
+	ln.MGamma = tensor.NewZeroTensor(ln.Features)
+	ln.Beta = tensor.NewZeroTensor(ln.Features)
+	ln.MBeta = tensor.NewZeroTensor(ln.Features)
+	ln.PreLayer = nil
+	return nil
+}
+
+func (ln *LayerNorm) SetPrelayer(lay Layer) error {
+	if ln.PreLayer != nil && lay != nil && !tensor.CompareShape(ln.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	ln.PreLayer = lay
+	return nil
+}
+
+func (ln *LayerNorm) Connect(preLayer Layer) error {
+	ln.Shape = preLayer.GetOutShape()
+	err := ln.Build()
+	if err != nil {
+		return err
+	}
+	ln.PreLayer = preLayer
+	return nil
+}
+
+func (ln *LayerNorm) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (ln *LayerNorm) Reset() error {
+	if ln.cOutput || ln.cDif != 0 {
+		ln.cOutput = false
+		ln.cDif = 0
+		ln.cFit = false
+		ln.grads = nil
+		ln.cGrads = false
+		ln.sent = nil
+		if ln.PreLayer != nil {
+			return ln.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (ln *LayerNorm) FullReset() error {
+	return ln.Reset()
+}
+
+func (ln *LayerNorm) GetInput() tensor.Tensor {
+	return ln.input
+}
+
+func (ln *LayerNorm) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if ln.cOutput {
+		return ln.output, nil
+	}
+	if ln.PreLayer != nil {
+		var err error
+		input, err = ln.PreLayer.Output(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ln.GetOne(input)
+}
+
+func (ln *LayerNorm) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if ln.cOutput {
+		return ln.output, nil
+	}
+	if input.Size() != tensor.MulIndex(ln.Shape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	input.Reshape(ln.Shape...)
+	ln.input = input
+
+	size := float64(input.Size())
+	mean := input.Sum() / size
+	variance := 0.0
+	for _, x := range input.GetData() {
+		variance += (x - mean) * (x - mean) / size
+	}
+	ln.scale = 1 / math.Sqrt(variance+ln.Epsilon)
+
+	ln.norm = tensor.NewZeroTensor(ln.Shape...)
+	out := tensor.NewZeroTensor(ln.Shape...)
+	norm := ln.norm.GetData()
+	data := out.GetData()
+	var f int
+	var g, b float64
+	for i, x := range input.GetData() {
+		f = i % ln.Features
+		g, _ = ln.Gamma.FGet(f)
+		b, _ = ln.Beta.FGet(f)
+		norm[i] = (x - mean) * ln.scale
+		data[i] = g*norm[i] + b
+	}
+	ln.output = out
+	ln.cOutput = true
+	return out, nil
+}
+
+func (ln *LayerNorm) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return ln.Get(input)
+}
+
+func (ln *LayerNorm) SetDif(dif tensor.Tensor) {
+	dif.Reshape(ln.Shape...)
+	if ln.cDif == 0 {
+		ln.dif = dif
+	} else {
+		ln.dif.AddTensor(dif)
+	}
+	ln.cDif++
+}
+
+func (ln *LayerNorm) Dif() error {
+	if ln.PreLayer != nil {
+		dif, err := unsentDif(ln.dif, &ln.sent)
+		if err != nil {
+			return err
+		}
+		der, err := ln.PreLayer.GetOne(ln.PreLayer.GetInput())
+		if err != nil {
+			return err
+		}
+		der.Reshape(ln.Shape...)
+		der, err = ln.PreLayer.GetActivation().Derive(der)
+		if err != nil {
+			return err
+		}
+
+		norm := ln.norm.GetData()
+		dNorm := make([]float64, len(norm))
+		sum, sumNorm := 0.0, 0.0
+		var g float64
+		for i, d := range dif.GetData() {
+			g, _ = ln.Gamma.FGet(i % ln.Features)
+			dNorm[i] = d * g
+			sum += dNorm[i]
+			sumNorm += dNorm[i] * norm[i]
+		}
+
+		size := float64(len(norm))
+		out := tensor.NewZeroTensor(ln.Shape...)
+		data := out.GetData()
+		var d float64
+		for i := range data {
+			d, _ = der.FGet(i)
+			data[i] = ln.scale * (dNorm[i] - sum/size - norm[i]*sumNorm/size) * d
+		}
+
+		ln.PreLayer.SetDif(out)
+		err = ln.PreLayer.Dif()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ln *LayerNorm) SetTrainable(t bool) {
+	ln.Trainable = t
+}
+
+func (ln *LayerNorm) SetTraining(t bool) {
+	if ln.PreLayer != nil {
+		ln.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of Gamma and Beta for the last input,
+// once per step.
+func (ln *LayerNorm) calGrads() []tensor.Tensor {
+	if ln.grads != nil {
+		return ln.grads
+	}
+	gamma := tensor.NewZeroTensor(ln.Features)
+	beta := tensor.NewZeroTensor(ln.Features)
+	norm := ln.norm.GetData()
+	for i, d := range ln.dif.GetData() {
+		gamma.AddAt(d*norm[i], i%ln.Features)
+		beta.AddAt(d, i%ln.Features)
+	}
+	ln.grads = []tensor.Tensor{gamma, beta}
+	return ln.grads
+}
+
+func (ln *LayerNorm) Fit(alpha float64, momentum float64) error {
+	if ln.cFit {
+		return nil
+	}
+	ln.cFit = true
+	if ln.Trainable {
+		update(alpha, momentum,
+			[]tensor.Tensor{ln.Gamma, ln.Beta},
+			[]tensor.Tensor{ln.MGamma, ln.MBeta},
+			ln.calGrads())
+	}
+	if ln.PreLayer != nil {
+		return ln.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+func (ln *LayerNorm) GetPenalty() (float64, error) {
+	if ln.PreLayer != nil {
+		return ln.PreLayer.GetPenalty()
+	}
+	return 0, nil
+}
+
+func (ln *LayerNorm) GetGradients() ([][]tensor.Tensor, error) {
+	if ln.cGrads {
+		return nil, nil
+	}
+	ln.cGrads = true
+	var grads [][]tensor.Tensor
+	if ln.Trainable {
+		grads = append(grads, ln.calGrads())
+	}
+	if ln.PreLayer != nil {
+		pg, err := ln.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+func (ln *LayerNorm) ResetSL() error {
+	ln.wSL = false
+	if ln.PreLayer != nil {
+		return ln.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+func (ln *LayerNorm) GetWeights() (serialization.Weights, error) {
+	if ln.wSL {
+		return serialization.Weights{}, nil
+	}
+	ln.wSL = true
+	data := [][]float64{
+		ln.Gamma.GetData(),
+		ln.Beta.GetData(),
+	}
+
+	w := serialization.Weights{
+		Data: data,
+	}
+
+	if ln.PreLayer != nil {
+		pw, e := ln.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+		w.PreWeights = []serialization.Weights{pw}
+	}
+	return w, nil
+}
+
+func (ln *LayerNorm) SetWeights(w serialization.Weights) error {
+	if !ln.wSL {
+		ln.wSL = true
+		if w.Data != nil {
+			if len(w.Data) < 2 {
+				return errors.New("invalid weights len")
+			}
+			ln.Gamma.SetData(w.Data[0])
+			ln.Beta.SetData(w.Data[1])
+		}
+
+		if ln.PreLayer != nil && w.PreWeights != nil {
+			if len(w.PreWeights) == 0 {
+				return errors.New("invalid preWeights len")
+			}
+			return ln.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// MergeMode selects how several outputs are merged into one.
+type MergeMode int
+
+const (
+	// MergeConcat concatenates the outputs.
+	MergeConcat MergeMode = iota
+	// MergeSum adds the outputs.
+	MergeSum
+	// MergeAverage takes the mean of the outputs.
+	MergeAverage
+	// MergeSubtract subtracts the second output from the first one.
+	MergeSubtract
+	// MergeMultiply multiplies the outputs.
+	MergeMultiply
+	// MergeMaximum takes the maximum of the outputs.
+	MergeMaximum
+	// MergeMinimum takes the minimum of the outputs.
+	MergeMinimum
+	// MergeDot takes the dot product of two outputs along their last axis.
+	MergeDot
+)
+
+// Merge combines, value by value, the outputs of PreLayers, which must have
+// the same shape. MergeDot instead reduces the last axis of its two inputs,
+// so its output shape is the input shape without it ([1] for vectors).
+type Merge struct {
+	PreLayers []Layer
+	Mode      MergeMode
+	InShape   []int
+	Shape     []int
+
+	outs    []tensor.Tensor
+	output  tensor.Tensor
+	cOutput bool
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cDif    int
+
+	wSL bool
+}
+
+func NewMerge(mode MergeMode, layers ...Layer) (*Merge, error) {
+	if len(layers) < 1 {
+		return nil, errors.New("no layers given")
+	}
+	if (mode == MergeSubtract || mode == MergeDot) && len(layers) != 2 {
+		return nil, errors.New("this merge mode needs two layers")
+	}
+	shape := layers[0].GetOutShape()
+	for _, l := range layers {
+		tmp := l.GetOutShape()
+		if len(shape) != len(tmp) {
+			return nil, errors.New("incompatible layers outputs shape dimensions")
+		}
+		for i, s := range shape {
+			if s != tmp[i] {
+				return nil, errors.New("incompatible layers outputs shape")
+			}
+		}
+	}
+
+	merge := &Merge{
+		PreLayers: layers,
+		Mode:      mode,
+		InShape:   shape,
+		Shape:     shape,
+	}
+	switch mode {
+	case MergeSum, MergeAverage, MergeSubtract, MergeMultiply, MergeMaximum, MergeMinimum:
+	case MergeDot:
+		merge.Shape = []int{1}
+		if len(shape) > 1 {
+			merge.Shape = shape[:len(shape)-1]
+		}
+	default:
+		return nil, errors.New("invalid merge mode")
+	}
+	return merge, nil
+}
+
+func NewAdd(layers ...Layer) (*Merge, error) {
+	return NewMerge(MergeSum, layers...)
+}
+
+func NewSubtract(a, b Layer) (*Merge, error) {
+	return NewMerge(MergeSubtract, a, b)
+}
+
+func NewMultiply(layers ...Layer) (*Merge, error) {
+	return NewMerge(MergeMultiply, layers...)
+}
+
+func NewAverage(layers ...Layer) (*Merge, error) {
+	return NewMerge(MergeAverage, layers...)
+}
+
+func NewMaximum(layers ...Layer) (*Merge, error) {
+	return NewMerge(MergeMaximum, layers...)
+}
+
+func NewMinimum(layers ...Layer) (*Merge, error) {
+	return NewMerge(MergeMinimum, layers...)
+}
+
+func NewDot(a, b Layer) (*Merge, error) {
+	return NewMerge(MergeDot, a, b)
+}
+
+func (merge *Merge) GetOutShape() []int {
+	return merge.Shape
+}
+
+func (merge *Merge) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (merge *Merge) SetPrelayer(lay Layer) error {
+	if lay == nil {
+		return nil
+	}
+	return errors.New("invalid prelayer change")
+}
+
+func (merge *Merge) Connect(p Layer) error {
+	return nil
+}
+
+func (merge *Merge) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (merge *Merge) Reset() error {
+	if merge.cOutput || merge.cDif != 0 {
+		merge.cOutput = false
+		merge.cDif = 0
+		merge.sent = nil
+		var e error
+		for _, l := range merge.PreLayers {
+			e = l.Reset()
+			if e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (merge *Merge) FullReset() error {
+	return merge.Reset()
+}
+
+func (merge *Merge) GetInput() tensor.Tensor {
+	return merge.output
+}
+
+func (merge *Merge) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if merge.cOutput {
+		return merge.output, nil
+	}
+	merge.outs = make([]tensor.Tensor, len(merge.PreLayers))
+	var e error
+	for i, l := range merge.PreLayers {
+		merge.outs[i], e = l.Output(input)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	out := tensor.NewZeroTensor(merge.Shape...)
+	data := out.GetData()
+	if merge.Mode == MergeDot {
+		a := merge.outs[0].GetData()
+		b := merge.outs[1].GetData()
+		n := merge.InShape[len(merge.InShape)-1]
+		for i := range a {
+			data[i/n] += a[i] * b[i]
+		}
+	} else {
+		copy(data, merge.outs[0].GetData())
+		for _, o := range merge.outs[1:] {
+			for i, v := range o.GetData() {
+				switch merge.Mode {
+				case MergeSum, MergeAverage:
+					data[i] += v
+				case MergeSubtract:
+					data[i] -= v
+				case MergeMultiply:
+					data[i] *= v
+				case MergeMaximum:
+					if v > data[i] {
+						data[i] = v
+					}
+				case MergeMinimum:
+					if v < data[i] {
+						data[i] = v
+					}
+				}
+			}
+		}
+		if merge.Mode == MergeAverage {
+			out.MulNumber(1 / float64(len(merge.outs)))
+		}
+	}
+	merge.output = out
+	merge.cOutput = true
+	return out, nil
+}
+
+func (merge *Merge) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	return merge.Get(input)
+}
+
+func (merge *Merge) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return merge.Get(input)
+}
+
+func (merge *Merge) SetDif(dif tensor.Tensor) {
+	dif.Reshape(merge.Shape...)
+	if merge.cDif == 0 {
+		merge.dif = dif
+	} else {
+		merge.dif.AddTensor(dif)
+	}
+	merge.cDif++
+}
+
+// calDif returns the gradient of the output of the prelayer k.
+func (merge *Merge) calDif(dif tensor.Tensor, k int) tensor.Tensor {
+	out := tensor.NewZeroTensor(merge.InShape...)
+	data := out.GetData()
+	d := dif.GetData()
+	if merge.Mode == MergeDot {
+		other := merge.outs[1-k].GetData()
+		n := merge.InShape[len(merge.InShape)-1]
+		for i := range data {
+			data[i] = d[i/n] * other[i]
+		}
+		return out
+	}
+
+	self := merge.outs[k].GetData()
+	result := merge.output.GetData()
+	for i := range data {
+		switch merge.Mode {
+		case MergeSum:
+			data[i] = d[i]
+		case MergeAverage:
+			data[i] = d[i] / float64(len(merge.outs))
+		case MergeSubtract:
+			data[i] = d[i]
+			if k == 1 {
+				data[i] = -d[i]
+			}
+		case MergeMultiply:
+			data[i] = d[i]
+			for j, o := range merge.outs {
+				if j != k {
+					data[i] *= o.GetData()[i]
+				}
+			}
+		case MergeMaximum, MergeMinimum:
+			// Only the first prelayer holding the result gets the gradient.
+			if self[i] == result[i] && merge.first(k, i) {
+				data[i] = d[i]
+			}
+		}
+	}
+	return out
+}
+
+// first reports whether no prelayer before k has the merged value at i.
+func (merge *Merge) first(k, i int) bool {
+	result := merge.output.GetData()[i]
+	for _, o := range merge.outs[:k] {
+		if o.GetData()[i] == result {
+			return false
+		}
+	}
+	return true
+}
+
+func (merge *Merge) Dif() error {
+	dif, e := unsentDif(merge.dif, &merge.sent)
+	if e != nil {
+		return e
+	}
+	var der tensor.Tensor
+	for k, l := range merge.PreLayers {
+		der, e = l.GetOne(l.GetInput())
+		if e != nil {
+			return e
+		}
+		der.Reshape(merge.InShape...)
+		der, e = l.GetActivation().Derive(der)
+		if e != nil {
+			return e
+		}
+		out := merge.calDif(dif, k)
+		e = out.MulTensor(der)
+		if e != nil {
+			return e
+		}
+		l.SetDif(out)
+		e = l.Dif()
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (merge *Merge) SetTrainable(bool) {}
+
+func (merge *Merge) SetTraining(t bool) {
+	for _, l := range merge.PreLayers {
+		l.SetTraining(t)
+	}
+}
+
+func (merge *Merge) Fit(alpha, momentum float64) error {
+	var e error
+	for _, l := range merge.PreLayers {
+		e = l.Fit(alpha, momentum)
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (merge *Merge) GetPenalty() (float64, error) {
+	penalty := 0.0
+	for _, l := range merge.PreLayers {
+		p, e := l.GetPenalty()
+		if e != nil {
+			return 0, e
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (merge *Merge) GetGradients() ([][]tensor.Tensor, error) {
+	var grads [][]tensor.Tensor
+	for _, l := range merge.PreLayers {
+		g, e := l.GetGradients()
+		if e != nil {
+			return nil, e
+		}
+		grads = append(grads, g...)
+	}
+	return grads, nil
+}
+
+func (merge *Merge) ResetSL() error {
+	merge.wSL = false
+	var e error
+	for _, l := range merge.PreLayers {
+		e = l.ResetSL()
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (merge *Merge) GetWeights() (serialization.Weights, error) {
+	if merge.wSL {
+		return serialization.Weights{}, nil
+	}
+	merge.wSL = true
+	preWeights := make([]serialization.Weights, len(merge.PreLayers))
+	var e error
+	for i, l := range merge.PreLayers {
+		preWeights[i], e = l.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+	}
+	return serialization.Weights{PreWeights: preWeights}, nil
+}
+
+func (merge *Merge) SetWeights(w serialization.Weights) error {
+	if merge.wSL {
+		return nil
+	}
+	merge.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) < len(merge.PreLayers) {
+			return errors.New("invalid preWeights len")
+		}
+		for i, l := range merge.PreLayers {
+			e := l.SetWeights(w.PreWeights[i])
+			if e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
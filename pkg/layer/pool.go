@@ -0,0 +1,435 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// PoolMode selects how a Pool reduces every window.
+type PoolMode int
+
+const (
+	// PoolMax takes the maximum of the window.
+	PoolMax PoolMode = iota
+	// PoolAverage takes the mean of the window, ignoring the padding.
+	PoolAverage
+)
+
+// Padding selects how a window that slides over an input handles its borders.
+type Padding int
+
+const (
+	// PaddingValid only uses the positions where the window fits in the input.
+	PaddingValid Padding = iota
+	// PaddingSame pads the input so the output size is the input size
+	// divided by the stride, rounded up.
+	PaddingSame
+	// PaddingExplicit pads the input with the padding set in the layer.
+	PaddingExplicit
+)
+
+// MaxPool2D is the name of the pooling layer before it learned other modes.
+type MaxPool2D = Pool
+
+// Pool reduces the windows of a channels-last input ([steps, c], [w, h, c],
+// ...) with the maximum or the mean of every channel. A Global pool reduces
+// the whole input of every channel, so its output shape is [c].
+type Pool struct {
+	PreLayer Layer
+	Mode     PoolMode
+	Global   bool
+	Dims     int
+	Size     []int
+	Stride   []int
+	Padding  Padding
+
+	InShape []int
+	Shape   []int
+
+	windows [][]int
+	argmax  []int
+
+	input   tensor.Tensor
+	cOutput bool
+	output  tensor.Tensor
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cDif    int
+
+	wSL bool
+}
+
+func newPool(mode PoolMode, dims, size, stride int, padding Padding) *Pool {
+	pool := &Pool{
+		Mode:    mode,
+		Dims:    dims,
+		Size:    make([]int, dims),
+		Stride:  make([]int, dims),
+		Padding: padding,
+	}
+	for i := 0; i < dims; i++ {
+		pool.Size[i] = size
+		pool.Stride[i] = stride
+	}
+	return pool
+}
+
+func newGlobalPool(mode PoolMode, dims int) *Pool {
+	return &Pool{
+		Mode:   mode,
+		Global: true,
+		Dims:   dims,
+	}
+}
+
+func NewMaxPool1D(size, stride int, padding Padding) *Pool {
+	return newPool(PoolMax, 1, size, stride, padding)
+}
+
+func NewAvgPool1D(size, stride int, padding Padding) *Pool {
+	return newPool(PoolAverage, 1, size, stride, padding)
+}
+
+// NewMaxPool2D returns the 2x2 max pool of stride 2 that keeps the last
+// incomplete windows, so its output size is the input size divided by 2,
+// rounded up.
+func NewMaxPool2D() *Pool {
+	return newPool(PoolMax, 2, 2, 2, PaddingSame)
+}
+
+// NewMaxPool2DWindow returns a max pool of size x size windows that move
+// stride positions at a time.
+func NewMaxPool2DWindow(size, stride int, padding Padding) *Pool {
+	return newPool(PoolMax, 2, size, stride, padding)
+}
+
+func NewAvgPool2D(size, stride int, padding Padding) *Pool {
+	return newPool(PoolAverage, 2, size, stride, padding)
+}
+
+func NewMaxPool3D(size, stride int, padding Padding) *Pool {
+	return newPool(PoolMax, 3, size, stride, padding)
+}
+
+func NewAvgPool3D(size, stride int, padding Padding) *Pool {
+	return newPool(PoolAverage, 3, size, stride, padding)
+}
+
+func NewGlobalMaxPool1D() *Pool {
+	return newGlobalPool(PoolMax, 1)
+}
+
+func NewGlobalAvgPool1D() *Pool {
+	return newGlobalPool(PoolAverage, 1)
+}
+
+func NewGlobalMaxPool2D() *Pool {
+	return newGlobalPool(PoolMax, 2)
+}
+
+func NewGlobalAvgPool2D() *Pool {
+	return newGlobalPool(PoolAverage, 2)
+}
+
+func NewGlobalMaxPool3D() *Pool {
+	return newGlobalPool(PoolMax, 3)
+}
+
+func NewGlobalAvgPool3D() *Pool {
+	return newGlobalPool(PoolAverage, 3)
+}
+
+func (pool *Pool) GetOutShape() []int {
+	return pool.Shape
+}
+
+func (pool *Pool) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (pool *Pool) SetPrelayer(lay Layer) error {
+	if pool.PreLayer != nil && lay != nil && !tensor.CompareShape(pool.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	pool.PreLayer = lay
+	return nil
+}
+
+func (pool *Pool) Connect(p Layer) error {
+	inShape := p.GetOutShape()
+	if len(inShape) != pool.Dims+1 {
+		return errors.New("invalid input shape")
+	}
+	if pool.Padding == PaddingExplicit {
+		return errors.New("invalid padding")
+	}
+	channels := inShape[pool.Dims]
+	spatial := inShape[:pool.Dims]
+	size := pool.Size
+	stride := pool.Stride
+	pad := make([]int, pool.Dims)
+	out := make([]int, pool.Dims)
+	if pool.Global {
+		size = spatial
+		stride = spatial
+		for i := range out {
+			out[i] = 1
+		}
+	} else {
+		if len(size) != pool.Dims || len(stride) != pool.Dims {
+			return errors.New("invalid pool size or stride")
+		}
+		for i := range out {
+			if size[i] < 1 || stride[i] < 1 {
+				return errors.New("invalid pool size or stride")
+			}
+			if pool.Padding == PaddingSame {
+				out[i] = (spatial[i]-1)/stride[i] + 1
+				pad[i] = ((out[i]-1)*stride[i] + size[i] - spatial[i]) / 2
+				if pad[i] < 0 {
+					pad[i] = 0
+				}
+			} else {
+				out[i] = (spatial[i]-size[i])/stride[i] + 1
+				if spatial[i] < size[i] {
+					return errors.New("pool size bigger than the input")
+				}
+			}
+		}
+	}
+
+	pool.windows = poolWindows(spatial, out, size, stride, pad)
+	pool.InShape = inShape
+	if pool.Global {
+		pool.Shape = []int{channels}
+	} else {
+		pool.Shape = append(out, channels)
+	}
+	pool.PreLayer = p
+	return nil
+}
+
+// poolWindows returns, for every output position, the flat spatial indices
+// of the input positions in its window.
+func poolWindows(in, out, size, stride, pad []int) [][]int {
+	windows := make([][]int, tensor.MulIndex(out, -1))
+	pos := make([]int, len(out))
+	offset := make([]int, len(out))
+	for o := range windows {
+		for i := range offset {
+			offset[i] = 0
+		}
+		for {
+			index := 0
+			inside := true
+			for i := range pos {
+				p := pos[i]*stride[i] - pad[i] + offset[i]
+				if p < 0 || p >= in[i] {
+					inside = false
+					break
+				}
+				index = index*in[i] + p
+			}
+			if inside {
+				windows[o] = append(windows[o], index)
+			}
+			if !nextIndex(offset, size) {
+				break
+			}
+		}
+		nextIndex(pos, out)
+	}
+	return windows
+}
+
+// nextIndex moves index to the next position of shape in row major order
+// and reports false when it wraps around.
+func nextIndex(index, shape []int) bool {
+	for i := len(index) - 1; i >= 0; i-- {
+		index[i]++
+		if index[i] < shape[i] {
+			return true
+		}
+		index[i] = 0
+	}
+	return false
+}
+
+func (pool *Pool) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (pool *Pool) Reset() error {
+	if pool.cOutput || pool.cDif != 0 {
+		pool.cOutput = false
+		pool.cDif = 0
+		pool.sent = nil
+		return pool.PreLayer.Reset()
+	}
+	return nil
+}
+
+func (pool *Pool) FullReset() error {
+	return pool.Reset()
+}
+
+func (pool *Pool) GetInput() tensor.Tensor {
+	return pool.input
+}
+
+func (pool *Pool) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if pool.cOutput {
+		return pool.output, nil
+	}
+	var e error
+	input, e = pool.PreLayer.Output(input)
+	if e != nil {
+		return nil, e
+	}
+	return pool.GetOne(input)
+}
+
+func (pool *Pool) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if pool.cOutput {
+		return pool.output, nil
+	}
+	if input.Size() != tensor.MulIndex(pool.InShape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	pool.input = input
+	channels := pool.InShape[pool.Dims]
+	in := input.GetData()
+	pool.output = tensor.NewZeroTensor(pool.Shape...)
+	out := pool.output.GetData()
+	pool.argmax = make([]int, len(out))
+	for o, window := range pool.windows {
+		for c := 0; c < channels; c++ {
+			index := o*channels + c
+			if pool.Mode == PoolMax {
+				best := window[0]*channels + c
+				for _, w := range window[1:] {
+					if in[w*channels+c] > in[best] {
+						best = w*channels + c
+					}
+				}
+				pool.argmax[index] = best
+				out[index] = in[best]
+			} else {
+				for _, w := range window {
+					out[index] += in[w*channels+c]
+				}
+				out[index] /= float64(len(window))
+			}
+		}
+	}
+	pool.cOutput = true
+	return pool.output, nil
+}
+
+func (pool *Pool) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return pool.Get(input)
+}
+
+func (pool *Pool) SetDif(dif tensor.Tensor) {
+	dif.Reshape(pool.Shape...)
+	if pool.cDif == 0 {
+		pool.dif = dif
+	} else {
+		pool.dif.AddTensor(dif)
+	}
+	pool.cDif++
+}
+
+func (pool *Pool) Dif() error {
+	der, e := pool.PreLayer.GetOne(pool.PreLayer.GetInput())
+	if e != nil {
+		return e
+	}
+	der.Reshape(pool.InShape...)
+	der, e = pool.PreLayer.GetActivation().Derive(der)
+	if e != nil {
+		return e
+	}
+
+	channels := pool.InShape[pool.Dims]
+	out := tensor.NewZeroTensor(pool.InShape...)
+	data := out.GetData()
+	unsent, e := unsentDif(pool.dif, &pool.sent)
+	if e != nil {
+		return e
+	}
+	dif := unsent.GetData()
+	for o, window := range pool.windows {
+		for c := 0; c < channels; c++ {
+			index := o*channels + c
+			if pool.Mode == PoolMax {
+				data[pool.argmax[index]] += dif[index]
+			} else {
+				for _, w := range window {
+					data[w*channels+c] += dif[index] / float64(len(window))
+				}
+			}
+		}
+	}
+	e = out.MulTensor(der)
+	if e != nil {
+		return e
+	}
+
+	pool.PreLayer.SetDif(out)
+	return pool.PreLayer.Dif()
+}
+
+func (pool *Pool) SetTrainable(bool) {}
+
+func (pool *Pool) SetTraining(t bool) {
+	pool.PreLayer.SetTraining(t)
+}
+
+func (pool *Pool) Fit(alpha, momentum float64) error {
+	return pool.PreLayer.Fit(alpha, momentum)
+}
+
+func (pool *Pool) GetPenalty() (float64, error) {
+	return pool.PreLayer.GetPenalty()
+}
+
+func (pool *Pool) GetGradients() ([][]tensor.Tensor, error) {
+	return pool.PreLayer.GetGradients()
+}
+
+func (pool *Pool) ResetSL() error {
+	pool.wSL = false
+	return pool.PreLayer.ResetSL()
+}
+
+func (pool *Pool) GetWeights() (serialization.Weights, error) {
+	if pool.wSL {
+		return serialization.Weights{}, nil
+	}
+	pool.wSL = true
+	pw, e := pool.PreLayer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw},
+	}, nil
+}
+
+func (pool *Pool) SetWeights(w serialization.Weights) error {
+	if pool.wSL {
+		return nil
+	}
+	pool.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) == 0 {
+			return errors.New("invalid preWeights len")
+		}
+		return pool.PreLayer.SetWeights(w.PreWeights[0])
+	}
+	return nil
+}
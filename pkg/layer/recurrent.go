@@ -4,19 +4,24 @@ import (
 	"errors"
 
 	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/initializer"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
 )
 
 type Recurrent struct {
-	Weights    tensor.Tensor
-	MWeights   tensor.Tensor
-	Bias       tensor.Tensor
-	MBias      tensor.Tensor
-	Activation activation.Activation
-	NIn        int
-	NOut       int
-	PreLayer   Layer
+	Weights           tensor.Tensor
+	MWeights          tensor.Tensor
+	Bias              tensor.Tensor
+	MBias             tensor.Tensor
+	Activation        activation.Activation
+	KernelInitializer initializer.Initializer
+	BiasInitializer   initializer.Initializer
+	NIn               int
+	NOut              int
+	PreLayer          Layer
+
+	Regularization
 
 	Trainable bool
 
@@ -25,9 +30,13 @@ type Recurrent struct {
 	cOutput bool
 	output  tensor.Tensor
 
-	input tensor.Tensor
-	dif   tensor.Tensor
-	cDif  int
+	input  tensor.Tensor
+	dif    tensor.Tensor
+	sent   tensor.Tensor
+	cDif   int
+	cFit   bool
+	grads  []tensor.Tensor
+	cGrads bool
 
 	wSL bool
 }
@@ -64,10 +73,17 @@ func (recurrent *Recurrent) Build() error {
 	if recurrent.Activation == nil {
 		recurrent.Activation = &activation.Relu{}
 	}
+	if recurrent.KernelInitializer == nil {
+		recurrent.KernelInitializer = initializer.Default
+	}
+	if recurrent.BiasInitializer == nil {
+		recurrent.BiasInitializer = initializer.Default
+	}
 	recurrent.NIn += recurrent.NOut
-	recurrent.Weights = tensor.NewWeightTensor(recurrent.NOut, recurrent.NIn)
+	fanIn, fanOut := initializer.Fans(recurrent.NOut, recurrent.NIn)
+	recurrent.Weights = recurrent.KernelInitializer.Init(fanIn, fanOut, recurrent.NOut, recurrent.NIn)
 	recurrent.MWeights = tensor.NewZeroTensor(recurrent.NOut, recurrent.NIn)
-	recurrent.Bias = tensor.NewWeightTensor(recurrent.NOut)
+	recurrent.Bias = recurrent.BiasInitializer.Init(fanIn, fanOut, recurrent.NOut)
 	recurrent.MBias = tensor.NewZeroTensor(recurrent.NOut)
 	recurrent.PreLayer = nil
 	return nil
@@ -104,6 +120,11 @@ func (recurrent *Recurrent) Reset() error {
 		recurrent.cNeta = false
 		recurrent.cOutput = false
 		recurrent.cDif = 0
+		recurrent.cFit = false
+		recurrent.sent = nil
+		recurrent.resetPenalty()
+		recurrent.grads = nil
+		recurrent.cGrads = false
 		if recurrent.PreLayer != nil {
 			return recurrent.PreLayer.Reset()
 		}
@@ -197,7 +218,15 @@ func (recurrent *Recurrent) SetDif(dif tensor.Tensor) {
 }
 
 func (recurrent *Recurrent) Dif() error {
+	err := recurrent.addActivityDif(&recurrent.dif, recurrent.Activation, recurrent.neta, recurrent.output)
+	if err != nil {
+		return err
+	}
 	if recurrent.PreLayer != nil {
+		dif, err := unsentDif(recurrent.dif, &recurrent.sent)
+		if err != nil {
+			return err
+		}
 		der, err := recurrent.PreLayer.GetOne(recurrent.PreLayer.GetInput())
 		if err != nil {
 			return err
@@ -213,7 +242,7 @@ func (recurrent *Recurrent) Dif() error {
 		var d, w float64
 		for i := 0; i < recurrent.Weights.ShapeAt(1); i++ {
 			for j := 0; j < recurrent.Weights.ShapeAt(0); j++ {
-				d, _ = recurrent.dif.FGet(j)
+				d, _ = dif.FGet(j)
 				w, _ = recurrent.Weights.Get(j, i)
 				out.AddAt(d*w, i)
 			}
@@ -234,23 +263,55 @@ func (recurrent *Recurrent) SetTrainable(t bool) {
 	recurrent.Trainable = t
 }
 
+func (recurrent *Recurrent) SetTraining(t bool) {
+	if recurrent.PreLayer != nil {
+		recurrent.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of the weights and bias for the last
+// input, once per step.
+func (recurrent *Recurrent) calGrads() error {
+	if recurrent.grads != nil {
+		return nil
+	}
+	err := recurrent.addActivityDif(&recurrent.dif, recurrent.Activation, recurrent.neta, recurrent.output)
+	if err != nil {
+		return err
+	}
+	weights := tensor.NewZeroTensor(recurrent.NOut, recurrent.NIn)
+	w := weights.GetData()
+	in := recurrent.input.GetData()
+	for i, d := range recurrent.dif.GetData() {
+		for j, x := range in {
+			w[i*recurrent.NIn+j] = d * x
+		}
+	}
+	bias := recurrent.dif.Copy()
+	bias.Reshape(recurrent.NOut)
+	err = recurrent.regularize(recurrent.Weights, recurrent.Bias, weights, bias)
+	if err != nil {
+		return err
+	}
+	recurrent.grads = []tensor.Tensor{weights, bias}
+	return nil
+}
+
 func (recurrent *Recurrent) Fit(alpha float64, momentum float64) error {
+	if recurrent.cFit {
+		return nil
+	}
+	recurrent.cFit = true
 	if recurrent.Trainable {
-		var val, v, m float64
-		for i := 0; i < recurrent.Weights.ShapeAt(0); i++ {
-			m, _ = recurrent.dif.FGet(i)
-			val = alpha * m
-			for j := 0; j < recurrent.Weights.ShapeAt(1); j++ {
-				v, _ = recurrent.input.FGet(j)
-				v *= val
-				m, _ = recurrent.MWeights.Get(i, j)
-				recurrent.Weights.AddAt(v+m*momentum, i, j)
-				recurrent.MWeights.Set(v, i, j)
-			}
-			m, _ = recurrent.MBias.Get(i)
-			recurrent.Bias.AddAt(val+m*momentum, i)
-			recurrent.MBias.Set(val, i)
+		err := recurrent.calGrads()
+		if err != nil {
+			return err
 		}
+		update(alpha, momentum,
+			[]tensor.Tensor{recurrent.Weights, recurrent.Bias},
+			[]tensor.Tensor{recurrent.MWeights, recurrent.MBias},
+			recurrent.grads)
+		recurrent.constrain(recurrent.Weights, recurrent.Bias)
 	}
 	if recurrent.PreLayer != nil {
 		return recurrent.PreLayer.Fit(alpha, momentum)
@@ -258,6 +319,44 @@ func (recurrent *Recurrent) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (recurrent *Recurrent) GetPenalty() (float64, error) {
+	if recurrent.cPenalty {
+		return 0, nil
+	}
+	penalty := recurrent.penalty(recurrent.Weights, recurrent.Bias, recurrent.output)
+	if recurrent.PreLayer != nil {
+		p, err := recurrent.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+func (recurrent *Recurrent) GetGradients() ([][]tensor.Tensor, error) {
+	if recurrent.cGrads {
+		return nil, nil
+	}
+	recurrent.cGrads = true
+	var grads [][]tensor.Tensor
+	if recurrent.Trainable {
+		err := recurrent.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, recurrent.grads)
+	}
+	if recurrent.PreLayer != nil {
+		pg, err := recurrent.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
 func (recurrent *Recurrent) ResetSL() error {
 	recurrent.wSL = false
 	if recurrent.PreLayer != nil {
@@ -0,0 +1,93 @@
+package layer
+
+import (
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/constraint"
+	"github.com/julioguillermo/neuralnetwork/pkg/regularizer"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Regularization holds the regularizers and constraints of a layer with
+// weights. The kernel and bias regularizers penalize the weights, and the
+// activity one the output of the layer. The constraints are applied after
+// every update of the weights.
+type Regularization struct {
+	KernelRegularizer   regularizer.Regularizer
+	BiasRegularizer     regularizer.Regularizer
+	ActivityRegularizer regularizer.Regularizer
+	KernelConstraint    constraint.Constraint
+	BiasConstraint      constraint.Constraint
+
+	cActivity bool
+	cPenalty  bool
+}
+
+func (reg *Regularization) resetPenalty() {
+	reg.cActivity = false
+	reg.cPenalty = false
+}
+
+// penalty returns the penalty of the weights and output of the layer and
+// marks it as counted until the next Reset, so a layer reached through
+// several paths is counted once.
+func (reg *Regularization) penalty(weights, bias, output tensor.Tensor) float64 {
+	reg.cPenalty = true
+	penalty := 0.0
+	if reg.KernelRegularizer != nil && weights != nil {
+		penalty += reg.KernelRegularizer.Penalty(weights)
+	}
+	if reg.BiasRegularizer != nil && bias != nil {
+		penalty += reg.BiasRegularizer.Penalty(bias)
+	}
+	if reg.ActivityRegularizer != nil && output != nil {
+		penalty += reg.ActivityRegularizer.Penalty(output)
+	}
+	return penalty
+}
+
+// addActivityDif adds to dif, once per step, the part of the gradient of the
+// neta that comes from the activity regularizer.
+func (reg *Regularization) addActivityDif(dif *tensor.Tensor, act activation.Activation, neta, output tensor.Tensor) error {
+	if reg.ActivityRegularizer == nil || reg.cActivity || *dif == nil || output == nil {
+		return nil
+	}
+	reg.cActivity = true
+	der, err := act.Derive(neta)
+	if err != nil {
+		return err
+	}
+	grad := reg.ActivityRegularizer.Gradient(output)
+	grad.Reshape((*dif).GetShape()...)
+	der.Reshape((*dif).GetShape()...)
+	err = grad.MulTensor(der)
+	if err != nil {
+		return err
+	}
+	*dif = (*dif).Copy()
+	return (*dif).SubTensor(grad)
+}
+
+// regularize subtracts from the gradients of the weights and bias the
+// gradients of their regularizers.
+func (reg *Regularization) regularize(weights, bias, kernelGrad, biasGrad tensor.Tensor) error {
+	if reg.KernelRegularizer != nil {
+		err := kernelGrad.SubTensor(reg.KernelRegularizer.Gradient(weights))
+		if err != nil {
+			return err
+		}
+	}
+	if reg.BiasRegularizer != nil {
+		return biasGrad.SubTensor(reg.BiasRegularizer.Gradient(bias))
+	}
+	return nil
+}
+
+// constrain applies the constraints to the updated weights and bias.
+func (reg *Regularization) constrain(weights, bias tensor.Tensor) {
+	if reg.KernelConstraint != nil {
+		reg.KernelConstraint.Apply(weights)
+	}
+	if reg.BiasConstraint != nil {
+		reg.BiasConstraint.Apply(bias)
+	}
+}
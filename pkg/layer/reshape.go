@@ -118,10 +118,22 @@ func (reshape *Reshape) Dif() error {
 
 func (reshape *Reshape) SetTrainable(bool) {}
 
+func (reshape *Reshape) SetTraining(t bool) {
+	reshape.PreLayer.SetTraining(t)
+}
+
 func (reshape *Reshape) Fit(alpha, momentum float64) error {
 	return reshape.PreLayer.Fit(alpha, momentum)
 }
 
+func (reshape *Reshape) GetPenalty() (float64, error) {
+	return reshape.PreLayer.GetPenalty()
+}
+
+func (reshape *Reshape) GetGradients() ([][]tensor.Tensor, error) {
+	return reshape.PreLayer.GetGradients()
+}
+
 func (reshape *Reshape) ResetSL() error {
 	reshape.wSL = false
 	return reshape.PreLayer.ResetSL()
@@ -0,0 +1,319 @@
+package layer
+
+import (
+	"errors"
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Interpolation selects how UpSampling2D computes the new values.
+type Interpolation int
+
+const (
+	// InterpolationNearest repeats every value.
+	InterpolationNearest Interpolation = iota
+	// InterpolationBilinear mixes the four nearest values by their distance.
+	InterpolationBilinear
+)
+
+// Resize2D scales a [w, h, c] input by SizeX and SizeY and then pads it
+// with Left and Right zero columns and Top and Bottom zero rows. A negative
+// padding crops the scaled input instead.
+type Resize2D struct {
+	PreLayer      Layer
+	SizeX         int
+	SizeY         int
+	Interpolation Interpolation
+	Left          int
+	Right         int
+	Top           int
+	Bottom        int
+
+	InShape []int
+	Shape   []int
+
+	// taps has, for every output position, the input positions and
+	// weights it is computed from.
+	taps [][]resizeTap
+
+	input   tensor.Tensor
+	cOutput bool
+	output  tensor.Tensor
+	dif     tensor.Tensor
+	sent    tensor.Tensor
+	cDif    int
+
+	wSL bool
+}
+
+type resizeTap struct {
+	index  int
+	weight float64
+}
+
+// UpSampling2D, ZeroPadding2D and Cropping2D are the layers built by their
+// constructors, which only set the scale or the padding of a Resize2D.
+type (
+	UpSampling2D  = Resize2D
+	ZeroPadding2D = Resize2D
+	Cropping2D    = Resize2D
+)
+
+func NewUpSampling2D(sizeX, sizeY int, interpolation Interpolation) *Resize2D {
+	return &Resize2D{
+		SizeX:         sizeX,
+		SizeY:         sizeY,
+		Interpolation: interpolation,
+	}
+}
+
+func NewZeroPadding2D(left, right, top, bottom int) *Resize2D {
+	return &Resize2D{
+		SizeX:  1,
+		SizeY:  1,
+		Left:   left,
+		Right:  right,
+		Top:    top,
+		Bottom: bottom,
+	}
+}
+
+func NewCropping2D(left, right, top, bottom int) *Resize2D {
+	return NewZeroPadding2D(-left, -right, -top, -bottom)
+}
+
+func (res *Resize2D) GetOutShape() []int {
+	return res.Shape
+}
+
+func (res *Resize2D) Build() error {
+	return errors.New("this layer can not be used as model input")
+}
+
+func (res *Resize2D) SetPrelayer(lay Layer) error {
+	if res.PreLayer != nil && lay != nil && !tensor.CompareShape(res.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	res.PreLayer = lay
+	return nil
+}
+
+func (res *Resize2D) Connect(p Layer) error {
+	inShape := p.GetOutShape()
+	if len(inShape) != 3 {
+		return errors.New("invalid input shape")
+	}
+	if res.SizeX < 1 || res.SizeY < 1 {
+		return errors.New("invalid upsampling size")
+	}
+	if res.Interpolation != InterpolationNearest && res.Interpolation != InterpolationBilinear {
+		return errors.New("invalid interpolation")
+	}
+	w := inShape[0]*res.SizeX + res.Left + res.Right
+	h := inShape[1]*res.SizeY + res.Top + res.Bottom
+	if w < 1 || h < 1 {
+		return errors.New("cropping bigger than the input")
+	}
+
+	res.taps = make([][]resizeTap, w*h)
+	for x := 0; x < w; x++ {
+		tx := resizeAxis(x-res.Left, inShape[0], res.SizeX, res.Interpolation)
+		for y := 0; y < h; y++ {
+			ty := resizeAxis(y-res.Top, inShape[1], res.SizeY, res.Interpolation)
+			for _, a := range tx {
+				for _, b := range ty {
+					res.taps[x*h+y] = append(res.taps[x*h+y], resizeTap{
+						index:  a.index*inShape[1] + b.index,
+						weight: a.weight * b.weight,
+					})
+				}
+			}
+		}
+	}
+
+	res.InShape = inShape
+	res.Shape = []int{w, h, inShape[2]}
+	res.PreLayer = p
+	return nil
+}
+
+// resizeAxis returns the input positions and weights of the position p of
+// an axis of size in scaled by size, or nothing if p is in the padding.
+func resizeAxis(p, in, size int, interpolation Interpolation) []resizeTap {
+	if p < 0 || p >= in*size {
+		return nil
+	}
+	if interpolation == InterpolationNearest {
+		return []resizeTap{{index: p / size, weight: 1}}
+	}
+	src := (float64(p)+0.5)/float64(size) - 0.5
+	if src < 0 {
+		src = 0
+	}
+	i := int(math.Floor(src))
+	f := src - float64(i)
+	if i+1 >= in {
+		return []resizeTap{{index: in - 1, weight: 1}}
+	}
+	return []resizeTap{{index: i, weight: 1 - f}, {index: i + 1, weight: f}}
+}
+
+func (res *Resize2D) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (res *Resize2D) Reset() error {
+	if res.cOutput || res.cDif != 0 {
+		res.cOutput = false
+		res.cDif = 0
+		res.sent = nil
+		return res.PreLayer.Reset()
+	}
+	return nil
+}
+
+func (res *Resize2D) FullReset() error {
+	return res.Reset()
+}
+
+func (res *Resize2D) GetInput() tensor.Tensor {
+	return res.input
+}
+
+func (res *Resize2D) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if res.cOutput {
+		return res.output, nil
+	}
+	var e error
+	input, e = res.PreLayer.Output(input)
+	if e != nil {
+		return nil, e
+	}
+	return res.GetOne(input)
+}
+
+func (res *Resize2D) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if res.cOutput {
+		return res.output, nil
+	}
+	if input.Size() != tensor.MulIndex(res.InShape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	res.input = input
+	channels := res.InShape[2]
+	in := input.GetData()
+	res.output = tensor.NewZeroTensor(res.Shape...)
+	out := res.output.GetData()
+	for o, taps := range res.taps {
+		for _, t := range taps {
+			for c := 0; c < channels; c++ {
+				out[o*channels+c] += t.weight * in[t.index*channels+c]
+			}
+		}
+	}
+	res.cOutput = true
+	return res.output, nil
+}
+
+func (res *Resize2D) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return res.Get(input)
+}
+
+func (res *Resize2D) SetDif(dif tensor.Tensor) {
+	dif.Reshape(res.Shape...)
+	if res.cDif == 0 {
+		res.dif = dif
+	} else {
+		res.dif.AddTensor(dif)
+	}
+	res.cDif++
+}
+
+func (res *Resize2D) Dif() error {
+	der, e := res.PreLayer.GetOne(res.PreLayer.GetInput())
+	if e != nil {
+		return e
+	}
+	der.Reshape(res.InShape...)
+	der, e = res.PreLayer.GetActivation().Derive(der)
+	if e != nil {
+		return e
+	}
+
+	channels := res.InShape[2]
+	out := tensor.NewZeroTensor(res.InShape...)
+	data := out.GetData()
+	unsent, e := unsentDif(res.dif, &res.sent)
+	if e != nil {
+		return e
+	}
+	dif := unsent.GetData()
+	for o, taps := range res.taps {
+		for _, t := range taps {
+			for c := 0; c < channels; c++ {
+				data[t.index*channels+c] += t.weight * dif[o*channels+c]
+			}
+		}
+	}
+	e = out.MulTensor(der)
+	if e != nil {
+		return e
+	}
+
+	res.PreLayer.SetDif(out)
+	return res.PreLayer.Dif()
+}
+
+func (res *Resize2D) SetTrainable(bool) {}
+
+func (res *Resize2D) SetTraining(t bool) {
+	res.PreLayer.SetTraining(t)
+}
+
+func (res *Resize2D) Fit(alpha, momentum float64) error {
+	return res.PreLayer.Fit(alpha, momentum)
+}
+
+func (res *Resize2D) GetPenalty() (float64, error) {
+	return res.PreLayer.GetPenalty()
+}
+
+func (res *Resize2D) GetGradients() ([][]tensor.Tensor, error) {
+	return res.PreLayer.GetGradients()
+}
+
+func (res *Resize2D) ResetSL() error {
+	res.wSL = false
+	return res.PreLayer.ResetSL()
+}
+
+func (res *Resize2D) GetWeights() (serialization.Weights, error) {
+	if res.wSL {
+		return serialization.Weights{}, nil
+	}
+	res.wSL = true
+	pw, e := res.PreLayer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw},
+	}, nil
+}
+
+func (res *Resize2D) SetWeights(w serialization.Weights) error {
+	if res.wSL {
+		return nil
+	}
+	res.wSL = true
+	if w.PreWeights != nil {
+		if len(w.PreWeights) == 0 {
+			return errors.New("invalid preWeights len")
+		}
+		return res.PreLayer.SetWeights(w.PreWeights[0])
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// step is the prelayer of the layers wrapped by Bidirectional and similar
+// wrappers. It feeds them one slice of the wrapper input at a time and keeps
+// the gradient they send back for that slice.
+type step struct {
+	Shape []int
+	Size  int
+
+	value tensor.Tensor
+	dif   tensor.Tensor
+}
+
+func newStep(shape ...int) *step {
+	return &step{
+		Shape: shape,
+		Size:  tensor.MulIndex(shape, -1),
+	}
+}
+
+func (st *step) GetOutShape() []int {
+	return st.Shape
+}
+
+func (st *step) Build() error {
+	return nil
+}
+
+func (st *step) SetPrelayer(lay Layer) error {
+	if lay == nil {
+		return nil
+	}
+	return errors.New("invalid prelayer change")
+}
+
+func (st *step) Connect(preLayer Layer) error {
+	return errors.New("this layer can not be used as hidden layer")
+}
+
+func (st *step) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (st *step) Reset() error {
+	return nil
+}
+
+func (st *step) FullReset() error {
+	return nil
+}
+
+func (st *step) GetInput() tensor.Tensor {
+	return st.value
+}
+
+func (st *step) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	return st.value.Copy(), nil
+}
+
+func (st *step) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	return st.value.Copy(), nil
+}
+
+func (st *step) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return st.Get(input)
+}
+
+func (st *step) SetDif(dif tensor.Tensor) {
+	data := make([]float64, st.Size)
+	copy(data, dif.GetData())
+	st.dif = tensor.NewTensor(data, st.Shape...)
+}
+
+func (st *step) Dif() error {
+	return nil
+}
+
+func (st *step) SetTrainable(bool) {}
+
+func (st *step) SetTraining(bool) {}
+
+func (st *step) Fit(alpha, momentum float64) error {
+	return nil
+}
+
+func (st *step) GetPenalty() (float64, error) {
+	return 0, nil
+}
+
+func (st *step) GetGradients() ([][]tensor.Tensor, error) {
+	return nil, nil
+}
+
+func (st *step) ResetSL() error {
+	return nil
+}
+
+func (st *step) GetWeights() (serialization.Weights, error) {
+	return serialization.Weights{}, nil
+}
+
+func (st *step) SetWeights(w serialization.Weights) error {
+	return nil
+}
+
+// tape keeps the random values and statistics that the layers wrapped by
+// Bidirectional and TimeDistributed draw in training mode while the wrapper
+// runs them over a sequence, and gives them back in the same order when the
+// wrapper runs them again to compute their gradients. So the replayed steps
+// have the outputs of the first run, with the same dropout masks and without
+// updating the statistics of a BatchNorm again.
+type tape struct {
+	values    [][]tensor.Tensor
+	pos       int
+	recording bool
+}
+
+// record starts a new run, forgetting the values of the last one.
+func (tp *tape) record() {
+	tp.values = nil
+	tp.recording = true
+}
+
+// replay makes keep give back the recorded values from the first one.
+func (tp *tape) replay() {
+	tp.pos = 0
+	tp.recording = false
+}
+
+// keep returns the values made by draw while recording, and the next
+// recorded values while replaying. A nil tape always calls draw.
+func (tp *tape) keep(draw func() []tensor.Tensor) []tensor.Tensor {
+	if tp == nil {
+		return draw()
+	}
+	if tp.recording {
+		values := draw()
+		tp.values = append(tp.values, values)
+		return values
+	}
+	if tp.pos >= len(tp.values) {
+		return draw()
+	}
+	tp.pos++
+	return tp.values[tp.pos-1]
+}
+
+// taped is implemented by the layers that draw random values or update
+// statistics in training mode.
+type taped interface {
+	setTape(*tape)
+}
+
+// newTape returns the tape of a wrapped layer, which it uses if it is taped.
+func newTape(lay Layer) *tape {
+	tp := &tape{}
+	if t, ok := lay.(taped); ok {
+		t.setTape(tp)
+	}
+	return tp
+}
+
+// stepGrads sums the gradients of a wrapped layer over the steps of a
+// sequence, so the layer is fitted once with the sum.
+type stepGrads struct {
+	sum  [][]tensor.Tensor
+	last [][]tensor.Tensor
+}
+
+// add adds the gradients of the current step of lay.
+func (sg *stepGrads) add(lay Layer) error {
+	grads, err := lay.GetGradients()
+	if err != nil {
+		return err
+	}
+	if sg.sum == nil {
+		sg.sum = grads
+	} else {
+		if len(grads) != len(sg.sum) {
+			return errors.New("the steps have different gradients")
+		}
+		for i, group := range grads {
+			if len(group) != len(sg.sum[i]) {
+				return errors.New("the steps have different gradients")
+			}
+			for j, g := range group {
+				err = sg.sum[i][j].AddTensor(g)
+				if err != nil {
+					return errors.New("the steps have different gradients")
+				}
+			}
+		}
+	}
+	sg.last = grads
+	return nil
+}
+
+// done copies the sum to the gradients of the last step, which are the ones
+// the Fit of the layer applies while it keeps the state of that step, and
+// returns them.
+func (sg *stepGrads) done() [][]tensor.Tensor {
+	for i, group := range sg.last {
+		for j, g := range group {
+			copy(g.GetData(), sg.sum[i][j].GetData())
+		}
+	}
+	if sg.last == nil {
+		return [][]tensor.Tensor{}
+	}
+	return sg.last
+}
@@ -137,10 +137,22 @@ func (sub *SubTensor) Dif() error {
 
 func (sub *SubTensor) SetTrainable(bool) {}
 
+func (sub *SubTensor) SetTraining(t bool) {
+	sub.PreLayer.SetTraining(t)
+}
+
 func (sub *SubTensor) Fit(alpha float64, momentum float64) error {
 	return sub.PreLayer.Fit(alpha, momentum)
 }
 
+func (sub *SubTensor) GetPenalty() (float64, error) {
+	return sub.PreLayer.GetPenalty()
+}
+
+func (sub *SubTensor) GetGradients() ([][]tensor.Tensor, error) {
+	return sub.PreLayer.GetGradients()
+}
+
 func (sub *SubTensor) ResetSL() error {
 	sub.wSL = false
 	return sub.PreLayer.ResetSL()
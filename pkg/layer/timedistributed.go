@@ -0,0 +1,461 @@
+package layer
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// TimeDistributed applies Layer to every slice of a [steps, features...]
+// input along its first axis, so its output shape is [steps, out...]. All the
+// steps share the weights of Layer, which start from a clean state
+// (FullReset) for every slice, and Fit updates them once with the sum of the
+// gradients of every step.
+type TimeDistributed struct {
+	Layer    Layer
+	PreLayer Layer
+	InShape  []int
+	Shape    []int
+
+	Trainable bool
+
+	in      *step
+	tape    *tape
+	steps   []tensor.Tensor
+	ders    []tensor.Tensor
+	penalty float64
+
+	cOutput  bool
+	output   tensor.Tensor
+	input    tensor.Tensor
+	dif      tensor.Tensor
+	sent     tensor.Tensor
+	cFit     bool
+	grads    [][]tensor.Tensor
+	cGrads   bool
+	cPenalty bool
+	cDif     int
+
+	wSL bool
+}
+
+func NewTimeDistributed(lay Layer) *TimeDistributed {
+	return &TimeDistributed{
+		Layer:     lay,
+		Trainable: true,
+	}
+}
+
+func NewInTimeDistributed(inShape []int, lay Layer) *TimeDistributed {
+	return &TimeDistributed{
+		Layer:     lay,
+		InShape:   inShape,
+		Trainable: true,
+	}
+}
+
+func (td *TimeDistributed) GetOutShape() []int {
+	return td.Shape
+}
+
+func (td *TimeDistributed) Build() error {
+	if td.InShape == nil || len(td.InShape) < 2 || td.InShape[0] < 1 {
+		return errors.New("invalid input shape")
+	}
+	if td.Layer == nil {
+		return errors.New("no layer given")
+	}
+	td.in = newStep(td.InShape[1:]...)
+	err := td.Layer.Connect(td.in)
+	if err != nil {
+		return err
+	}
+	td.tape = newTape(td.Layer)
+	td.Shape = append([]int{td.InShape[0]}, td.Layer.GetOutShape()...)
+	td.PreLayer = nil
+	return nil
+}
+
+func (td *TimeDistributed) SetPrelayer(lay Layer) error {
+	if td.PreLayer != nil && lay != nil && !tensor.CompareShape(td.PreLayer.GetOutShape(), lay.GetOutShape()) {
+		return errors.New("invalid prelayers output shape")
+	}
+	td.PreLayer = lay
+	return nil
+}
+
+func (td *TimeDistributed) Connect(preLayer Layer) error {
+	td.InShape = preLayer.GetOutShape()
+	err := td.Build()
+	if err != nil {
+		return err
+	}
+	td.PreLayer = preLayer
+	return nil
+}
+
+func (td *TimeDistributed) GetActivation() activation.Activation {
+	return activation.ActLinear
+}
+
+func (td *TimeDistributed) Reset() error {
+	if td.cOutput || td.cDif != 0 {
+		td.cOutput = false
+		td.cDif = 0
+		td.cFit = false
+		td.grads = nil
+		td.cGrads = false
+		td.cPenalty = false
+		td.sent = nil
+		if td.PreLayer != nil {
+			return td.PreLayer.Reset()
+		}
+	}
+	return nil
+}
+
+func (td *TimeDistributed) FullReset() error {
+	return td.Reset()
+}
+
+func (td *TimeDistributed) GetInput() tensor.Tensor {
+	return td.input
+}
+
+func (td *TimeDistributed) Get(input tensor.Tensor) (tensor.Tensor, error) {
+	if td.cOutput {
+		return td.output, nil
+	}
+	if td.PreLayer != nil {
+		var err error
+		input, err = td.PreLayer.Output(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return td.GetOne(input)
+}
+
+// runStep feeds the step t to Layer, returning its output and the
+// derivative of its activation.
+func (td *TimeDistributed) runStep(t int) (tensor.Tensor, tensor.Tensor, error) {
+	err := td.Layer.FullReset()
+	if err != nil {
+		return nil, nil, err
+	}
+	td.in.value = td.steps[t]
+	out, err := td.Layer.Output(td.in.value)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := td.Layer.GetOne(td.Layer.GetInput())
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err = td.Layer.GetActivation().Derive(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, der, nil
+}
+
+func (td *TimeDistributed) GetOne(input tensor.Tensor) (tensor.Tensor, error) {
+	if td.cOutput {
+		return td.output, nil
+	}
+	if input.Size() != tensor.MulIndex(td.InShape, -1) {
+		return nil, errors.New("incompatible input shape")
+	}
+	input = input.Copy()
+	input.Reshape(td.InShape...)
+	td.input = input
+	td.steps = make([]tensor.Tensor, td.InShape[0])
+	td.ders = make([]tensor.Tensor, td.InShape[0])
+	out := tensor.NewZeroTensor(td.Shape...)
+	data := out.GetData()
+	size := tensor.MulIndex(td.Layer.GetOutShape(), -1)
+	var err error
+	var o tensor.Tensor
+	// What Layer draws in training mode is recorded for the replays.
+	td.tape.record()
+	defer td.tape.replay()
+	// The penalty of Layer is the one of every step, like its gradients.
+	td.penalty = 0
+	var p float64
+	for t := range td.steps {
+		td.steps[t], err = input.GetSubTensor(t)
+		if err != nil {
+			return nil, err
+		}
+		o, td.ders[t], err = td.runStep(t)
+		if err != nil {
+			return nil, err
+		}
+		p, err = td.Layer.GetPenalty()
+		if err != nil {
+			return nil, err
+		}
+		td.penalty += p
+		copy(data[t*size:(t+1)*size], o.GetData())
+	}
+	td.output = out
+	td.cOutput = true
+	return out, nil
+}
+
+func (td *TimeDistributed) Output(input tensor.Tensor) (tensor.Tensor, error) {
+	return td.Get(input)
+}
+
+func (td *TimeDistributed) SetDif(dif tensor.Tensor) {
+	dif.Reshape(td.Shape...)
+	if td.cDif == 0 {
+		td.dif = dif
+	} else {
+		td.dif.AddTensor(dif)
+	}
+	td.cDif++
+}
+
+// splitDif turns the gradient dif of the output into the gradients of the
+// neta of Layer at every step.
+func (td *TimeDistributed) splitDif(dif tensor.Tensor) ([]tensor.Tensor, error) {
+	difs := make([]tensor.Tensor, td.InShape[0])
+	var err error
+	for t := range difs {
+		difs[t], err = dif.GetSubTensor(t)
+		if err != nil {
+			return nil, err
+		}
+		difs[t] = difs[t].Copy()
+		difs[t].Reshape(td.Layer.GetOutShape()...)
+		err = difs[t].MulTensor(td.ders[t])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return difs, nil
+}
+
+// replay runs again Layer over the steps, with the values recorded by
+// GetOne, giving it the gradient of every step. The gradients of the inputs
+// are copied to out if it is not nil, and the gradients of Layer are added
+// to grads if it is not nil. Layer is left at the last step, ready to be
+// fitted with the sum.
+func (td *TimeDistributed) replay(difs []tensor.Tensor, out tensor.Tensor, grads *stepGrads) error {
+	td.tape.replay()
+	size := td.in.Size
+	for t := range td.steps {
+		_, _, err := td.runStep(t)
+		if err != nil {
+			return err
+		}
+		td.Layer.SetDif(difs[t].Copy())
+		if grads != nil {
+			err = grads.add(td.Layer)
+			if err != nil {
+				return err
+			}
+		}
+		if out == nil {
+			continue
+		}
+		err = td.Layer.Dif()
+		if err != nil {
+			return err
+		}
+		copy(out.GetData()[t*size:(t+1)*size], td.in.dif.GetData())
+	}
+	return nil
+}
+
+func (td *TimeDistributed) Dif() error {
+	if td.PreLayer == nil {
+		return nil
+	}
+	dif, err := unsentDif(td.dif, &td.sent)
+	if err != nil {
+		return err
+	}
+	difs, err := td.splitDif(dif)
+	if err != nil {
+		return err
+	}
+	// The replay leaves Layer at other gradients.
+	td.grads = nil
+	out := tensor.NewZeroTensor(td.InShape...)
+	err = td.replay(difs, out, nil)
+	if err != nil {
+		return err
+	}
+
+	der, err := td.PreLayer.GetOne(td.PreLayer.GetInput())
+	if err != nil {
+		return err
+	}
+	der.Reshape(td.InShape...)
+	der, err = td.PreLayer.GetActivation().Derive(der)
+	if err != nil {
+		return err
+	}
+	err = out.MulTensor(der)
+	if err != nil {
+		return err
+	}
+	td.PreLayer.SetDif(out)
+	return td.PreLayer.Dif()
+}
+
+func (td *TimeDistributed) SetTrainable(t bool) {
+	td.Trainable = t
+}
+
+func (td *TimeDistributed) SetTraining(t bool) {
+	td.Layer.SetTraining(t)
+	if td.PreLayer != nil {
+		td.PreLayer.SetTraining(t)
+	}
+}
+
+// calGrads computes the gradients of Layer for the whole input, once per
+// step.
+func (td *TimeDistributed) calGrads() ([][]tensor.Tensor, error) {
+	if td.grads != nil {
+		return td.grads, nil
+	}
+	if td.cDif == 0 {
+		return [][]tensor.Tensor{}, nil
+	}
+	difs, err := td.splitDif(td.dif)
+	if err != nil {
+		return nil, err
+	}
+	var grads stepGrads
+	err = td.replay(difs, nil, &grads)
+	if err != nil {
+		return nil, err
+	}
+	td.grads = grads.done()
+	return td.grads, nil
+}
+
+func (td *TimeDistributed) Fit(alpha float64, momentum float64) error {
+	if td.cFit {
+		return nil
+	}
+	td.cFit = true
+	if td.Trainable {
+		_, err := td.calGrads()
+		if err != nil {
+			return err
+		}
+		err = td.Layer.Fit(alpha, momentum)
+		if err != nil {
+			return err
+		}
+	}
+	if td.PreLayer != nil {
+		return td.PreLayer.Fit(alpha, momentum)
+	}
+	return nil
+}
+
+// GetPenalty returns the penalty of Layer summed over the steps of the last
+// input, counted once until the next Reset, and the one of the prelayer.
+func (td *TimeDistributed) GetPenalty() (float64, error) {
+	penalty := 0.0
+	if !td.cPenalty {
+		td.cPenalty = true
+		penalty = td.penalty
+	}
+	if td.PreLayer != nil {
+		p, err := td.PreLayer.GetPenalty()
+		if err != nil {
+			return 0, err
+		}
+		penalty += p
+	}
+	return penalty, nil
+}
+
+// GetGradients returns the gradients of Layer, summed over the steps, and
+// the ones of the prelayer.
+func (td *TimeDistributed) GetGradients() ([][]tensor.Tensor, error) {
+	if td.cGrads {
+		return nil, nil
+	}
+	td.cGrads = true
+	var grads [][]tensor.Tensor
+	if td.Trainable {
+		g, err := td.calGrads()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, g...)
+	}
+	if td.PreLayer != nil {
+		pg, err := td.PreLayer.GetGradients()
+		if err != nil {
+			return nil, err
+		}
+		grads = append(grads, pg...)
+	}
+	return grads, nil
+}
+
+func (td *TimeDistributed) ResetSL() error {
+	td.wSL = false
+	err := td.Layer.ResetSL()
+	if err != nil {
+		return err
+	}
+	if td.PreLayer != nil {
+		return td.PreLayer.ResetSL()
+	}
+	return nil
+}
+
+// GetWeights keeps the weights of the prelayer at PreWeights[0] and the ones
+// of Layer at PreWeights[1].
+func (td *TimeDistributed) GetWeights() (serialization.Weights, error) {
+	if td.wSL {
+		return serialization.Weights{}, nil
+	}
+	td.wSL = true
+	lw, e := td.Layer.GetWeights()
+	if e != nil {
+		return serialization.Weights{}, e
+	}
+	pw := serialization.Weights{}
+	if td.PreLayer != nil {
+		pw, e = td.PreLayer.GetWeights()
+		if e != nil {
+			return serialization.Weights{}, e
+		}
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{pw, lw},
+	}, nil
+}
+
+func (td *TimeDistributed) SetWeights(w serialization.Weights) error {
+	if !td.wSL {
+		td.wSL = true
+		if w.PreWeights == nil {
+			return nil
+		}
+		if len(w.PreWeights) < 2 {
+			return errors.New("invalid preWeights len")
+		}
+		err := td.Layer.SetWeights(w.PreWeights[1])
+		if err != nil {
+			return err
+		}
+		if td.PreLayer != nil {
+			return td.PreLayer.SetWeights(w.PreWeights[0])
+		}
+	}
+	return nil
+}
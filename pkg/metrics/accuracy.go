@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Accuracy is the fraction of samples whose output is of the class of the
+// target. Binary outputs are of class 1 when they are above Threshold.
+type Accuracy struct {
+	Threshold float64
+
+	correct int
+	total   int
+}
+
+func NewAccuracy() *Accuracy {
+	return &Accuracy{Threshold: 0.5}
+}
+
+func (acc *Accuracy) Name() string {
+	return "accuracy"
+}
+
+func (acc *Accuracy) Reset() {
+	acc.correct = 0
+	acc.total = 0
+}
+
+func (acc *Accuracy) Update(output, target tensor.Tensor) error {
+	err := checkSize(output, target)
+	if err != nil {
+		return err
+	}
+	if class(output, acc.Threshold) == class(target, 0.5) {
+		acc.correct++
+	}
+	acc.total++
+	return nil
+}
+
+func (acc *Accuracy) Result() float64 {
+	if acc.total == 0 {
+		return 0
+	}
+	return float64(acc.correct) / float64(acc.total)
+}
+
+// TopK is the fraction of samples whose target class is among the K
+// classes with the highest outputs.
+type TopK struct {
+	K int
+
+	correct int
+	total   int
+}
+
+func NewTopK(k int) *TopK {
+	return &TopK{K: k}
+}
+
+func (tk *TopK) Name() string {
+	return fmt.Sprintf("top_%d_accuracy", tk.K)
+}
+
+func (tk *TopK) Reset() {
+	tk.correct = 0
+	tk.total = 0
+}
+
+func (tk *TopK) Update(output, target tensor.Tensor) error {
+	err := checkSize(output, target)
+	if err != nil {
+		return err
+	}
+	data := output.GetData()
+	indices := make([]int, len(data))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return data[indices[i]] > data[indices[j]]
+	})
+	t := target.MaxIndex()
+	for i := 0; i < tk.K && i < len(indices); i++ {
+		if indices[i] == t {
+			tk.correct++
+			break
+		}
+	}
+	tk.total++
+	return nil
+}
+
+func (tk *TopK) Result() float64 {
+	if tk.total == 0 {
+		return 0
+	}
+	return float64(tk.correct) / float64(tk.total)
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// scores keeps the score of the Positive class and whether it is the class
+// of the target for every sample, for the metrics of a ranking.
+type scores struct {
+	Positive int
+
+	values []float64
+	labels []bool
+}
+
+func (s *scores) Reset() {
+	s.values = nil
+	s.labels = nil
+}
+
+func (s *scores) Update(output, target tensor.Tensor) error {
+	err := checkSize(output, target)
+	if err != nil {
+		return err
+	}
+	index := 0
+	if output.Size() > 1 {
+		index = s.Positive
+	}
+	score, err := output.FGet(index)
+	if err != nil {
+		return err
+	}
+	s.values = append(s.values, score)
+	s.labels = append(s.labels, class(target, 0.5) == s.Positive)
+	return nil
+}
+
+// sorted returns the indices of the samples from the highest score to the
+// lowest.
+func (s *scores) sorted() []int {
+	indices := make([]int, len(s.values))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return s.values[indices[i]] > s.values[indices[j]]
+	})
+	return indices
+}
+
+// ROCAUC is the area under the ROC curve of the scores of the Positive
+// class, which is the probability that a random sample of the class gets a
+// higher score than a random sample of another class.
+type ROCAUC struct {
+	scores
+}
+
+func NewROCAUC() *ROCAUC {
+	return &ROCAUC{scores{Positive: 1}}
+}
+
+func (auc *ROCAUC) Name() string {
+	return "roc_auc"
+}
+
+func (auc *ROCAUC) Result() float64 {
+	indices := auc.sorted()
+	pos, neg := 0, 0
+	area := 0.0
+	for i := 0; i < len(indices); {
+		// samples with the same score count as half above each other
+		tp, fp := 0, 0
+		j := i
+		for ; j < len(indices) && auc.scores.values[indices[j]] == auc.scores.values[indices[i]]; j++ {
+			if auc.labels[indices[j]] {
+				tp++
+			} else {
+				fp++
+			}
+		}
+		area += float64(fp) * (float64(pos) + float64(tp)/2)
+		pos += tp
+		neg += fp
+		i = j
+	}
+	if pos == 0 || neg == 0 {
+		return 0
+	}
+	return area / float64(pos*neg)
+}
+
+// PRAUC is the area under the precision-recall curve of the scores of the
+// Positive class, computed as the average precision.
+type PRAUC struct {
+	scores
+}
+
+func NewPRAUC() *PRAUC {
+	return &PRAUC{scores{Positive: 1}}
+}
+
+func (auc *PRAUC) Name() string {
+	return "pr_auc"
+}
+
+func (auc *PRAUC) Result() float64 {
+	indices := auc.sorted()
+	positives := 0
+	for _, l := range auc.labels {
+		if l {
+			positives++
+		}
+	}
+	if positives == 0 {
+		return 0
+	}
+	tp, seen := 0, 0
+	area := 0.0
+	for i := 0; i < len(indices); {
+		found := 0
+		j := i
+		for ; j < len(indices) && auc.scores.values[indices[j]] == auc.scores.values[indices[i]]; j++ {
+			if auc.labels[indices[j]] {
+				found++
+			}
+		}
+		tp += found
+		seen += j - i
+		area += float64(found) / float64(positives) * float64(tp) / float64(seen)
+		i = j
+	}
+	return area
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// ConfusionMatrix counts the samples of every target class (the rows of
+// Matrix) predicted as every class (the columns). The number of classes is
+// taken from the first sample. Binary outputs are of class 1 when they are
+// above Threshold. Its Result is the accuracy.
+type ConfusionMatrix struct {
+	Threshold float64
+	Matrix    [][]int
+}
+
+func NewConfusionMatrix() *ConfusionMatrix {
+	return &ConfusionMatrix{Threshold: 0.5}
+}
+
+func (cm *ConfusionMatrix) Name() string {
+	return "confusion_accuracy"
+}
+
+func (cm *ConfusionMatrix) Reset() {
+	cm.Matrix = nil
+}
+
+func (cm *ConfusionMatrix) Update(output, target tensor.Tensor) error {
+	err := checkSize(output, target)
+	if err != nil {
+		return err
+	}
+	n := classes(output)
+	if cm.Matrix == nil {
+		cm.Matrix = make([][]int, n)
+		for i := range cm.Matrix {
+			cm.Matrix[i] = make([]int, n)
+		}
+	} else if len(cm.Matrix) != n {
+		return errors.New("the number of classes changed")
+	}
+	cm.Matrix[class(target, 0.5)][class(output, cm.Threshold)]++
+	return nil
+}
+
+func (cm *ConfusionMatrix) Result() float64 {
+	correct, total := 0, 0
+	for i, row := range cm.Matrix {
+		for j, v := range row {
+			if i == j {
+				correct += v
+			}
+			total += v
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(correct) / float64(total)
+}
+
+// Counts returns the true positives, false positives and false negatives of
+// a class.
+func (cm *ConfusionMatrix) Counts(class int) (int, int, int) {
+	if class < 0 || class >= len(cm.Matrix) {
+		return 0, 0, 0
+	}
+	tp := cm.Matrix[class][class]
+	fp, fn := 0, 0
+	for i := range cm.Matrix {
+		if i != class {
+			fp += cm.Matrix[i][class]
+			fn += cm.Matrix[class][i]
+		}
+	}
+	return tp, fp, fn
+}
+
+// Support returns the number of samples of a class.
+func (cm *ConfusionMatrix) Support(class int) int {
+	if class < 0 || class >= len(cm.Matrix) {
+		return 0
+	}
+	s := 0
+	for _, v := range cm.Matrix[class] {
+		s += v
+	}
+	return s
+}
+
+// Average selects how Precision, Recall and F1 combine the classes.
+type Average int
+
+const (
+	// Binary only measures the Positive class.
+	Binary Average = iota
+	// Macro is the mean over the classes.
+	Macro
+	// Micro measures the counts of all the classes together.
+	Micro
+	// Weighted is the mean over the classes weighted by their samples.
+	Weighted
+)
+
+// score computes precision, recall or F1 from the counts of a class.
+type score func(tp, fp, fn int) float64
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func precision(tp, fp, fn int) float64 {
+	return ratio(tp, tp+fp)
+}
+
+func recall(tp, fp, fn int) float64 {
+	return ratio(tp, tp+fn)
+}
+
+func f1(tp, fp, fn int) float64 {
+	return ratio(2*tp, 2*tp+fp+fn)
+}
+
+// classScore is a metric computed from the confusion matrix of the samples.
+type classScore struct {
+	Average  Average
+	Positive int
+
+	ConfusionMatrix
+	name  string
+	score score
+}
+
+func newClassScore(name string, s score, average Average) classScore {
+	return classScore{
+		Average:         average,
+		Positive:        1,
+		ConfusionMatrix: ConfusionMatrix{Threshold: 0.5},
+		name:            name,
+		score:           s,
+	}
+}
+
+// Name is the name of the score with its average, as f1_macro, so that the
+// same score with several averages has a log for each. The Binary score
+// keeps the plain name.
+func (cs *classScore) Name() string {
+	switch cs.Average {
+	case Macro:
+		return cs.name + "_macro"
+	case Micro:
+		return cs.name + "_micro"
+	case Weighted:
+		return cs.name + "_weighted"
+	}
+	return cs.name
+}
+
+func (cs *classScore) Result() float64 {
+	switch cs.Average {
+	case Macro, Weighted:
+		sum, weights := 0.0, 0
+		for c := range cs.Matrix {
+			w := 1
+			if cs.Average == Weighted {
+				w = cs.Support(c)
+			}
+			sum += float64(w) * cs.score(cs.Counts(c))
+			weights += w
+		}
+		if weights == 0 {
+			return 0
+		}
+		return sum / float64(weights)
+	case Micro:
+		tp, fp, fn := 0, 0, 0
+		for c := range cs.Matrix {
+			t, p, n := cs.Counts(c)
+			tp += t
+			fp += p
+			fn += n
+		}
+		return cs.score(tp, fp, fn)
+	}
+	return cs.score(cs.Counts(cs.Positive))
+}
+
+// Precision is the fraction of the samples predicted as a class that are of
+// that class.
+type Precision struct {
+	classScore
+}
+
+func NewPrecision(average Average) *Precision {
+	return &Precision{newClassScore("precision", precision, average)}
+}
+
+// Recall is the fraction of the samples of a class predicted as that class.
+type Recall struct {
+	classScore
+}
+
+func NewRecall(average Average) *Recall {
+	return &Recall{newClassScore("recall", recall, average)}
+}
+
+// F1 is the harmonic mean of the precision and recall.
+type F1 struct {
+	classScore
+}
+
+func NewF1(average Average) *F1 {
+	return &F1{newClassScore("f1", f1, average)}
+}
@@ -0,0 +1,41 @@
+// Package metrics has streaming metrics for Train and Evaluate. Every metric
+// accumulates the samples given to Update until Reset, and Result gives its
+// value over all of them.
+//
+// The classification metrics take an output with a value per class and a
+// one-hot target, or a single probability and a 0 or 1 target for binary
+// classification.
+package metrics
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// class returns the class of a binary or one-hot tensor.
+func class(t tensor.Tensor, threshold float64) int {
+	if t.Size() == 1 {
+		v, _ := t.FGet(0)
+		if v > threshold {
+			return 1
+		}
+		return 0
+	}
+	return t.MaxIndex()
+}
+
+// classes returns the number of classes of a binary or one-hot tensor.
+func classes(t tensor.Tensor) int {
+	if t.Size() == 1 {
+		return 2
+	}
+	return t.Size()
+}
+
+func checkSize(output, target tensor.Tensor) error {
+	if output.Size() != target.Size() {
+		return errors.New("output and target sizes are different")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// errorSums accumulates the errors of every value of the outputs.
+type errorSums struct {
+	abs    float64
+	square float64
+	count  int
+}
+
+func (es *errorSums) Reset() {
+	*es = errorSums{}
+}
+
+func (es *errorSums) Update(output, target tensor.Tensor) error {
+	err := checkSize(output, target)
+	if err != nil {
+		return err
+	}
+	t := target.GetData()
+	for i, o := range output.GetData() {
+		d := o - t[i]
+		es.abs += math.Abs(d)
+		es.square += d * d
+	}
+	es.count += output.Size()
+	return nil
+}
+
+// MAE is the mean absolute error of the values of the outputs.
+type MAE struct {
+	errorSums
+}
+
+func NewMAE() *MAE {
+	return &MAE{}
+}
+
+func (mae *MAE) Name() string {
+	return "mae"
+}
+
+func (mae *MAE) Result() float64 {
+	if mae.count == 0 {
+		return 0
+	}
+	return mae.abs / float64(mae.count)
+}
+
+// RMSE is the root mean squared error of the values of the outputs.
+type RMSE struct {
+	errorSums
+}
+
+func NewRMSE() *RMSE {
+	return &RMSE{}
+}
+
+func (rmse *RMSE) Name() string {
+	return "rmse"
+}
+
+func (rmse *RMSE) Result() float64 {
+	if rmse.count == 0 {
+		return 0
+	}
+	return math.Sqrt(rmse.square / float64(rmse.count))
+}
+
+// R2 is the coefficient of determination of the values of the outputs, the
+// fraction of the variance of the targets explained by the model.
+type R2 struct {
+	errorSums
+	sum       float64
+	sumSquare float64
+}
+
+func NewR2() *R2 {
+	return &R2{}
+}
+
+func (r2 *R2) Name() string {
+	return "r2"
+}
+
+func (r2 *R2) Reset() {
+	*r2 = R2{}
+}
+
+func (r2 *R2) Update(output, target tensor.Tensor) error {
+	err := r2.errorSums.Update(output, target)
+	if err != nil {
+		return err
+	}
+	for _, t := range target.GetData() {
+		r2.sum += t
+		r2.sumSquare += t * t
+	}
+	return nil
+}
+
+func (r2 *R2) Result() float64 {
+	if r2.count == 0 {
+		return 0
+	}
+	total := r2.sumSquare - r2.sum*r2.sum/float64(r2.count)
+	if total == 0 {
+		return 0
+	}
+	return 1 - r2.square/total
+}
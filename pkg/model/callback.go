@@ -0,0 +1,59 @@
+package model
+
+// Logs are the values of a training event, keyed by name: "loss" and
+// "rate" for the learning rate, and "grad_norm" for the pre-clip gradient
+// norm of a batch. The logs of OnTrainBegin are the number of "epochs" and
+// "batches" of the training, -1 batches if the dataset is streamed.
+type Logs map[string]float64
+
+// Callback is notified by Train at the beginning and end of the training,
+// of every epoch and of every batch. The epochs are counted along all the
+// Train calls of the model, and the batches from 0 in every epoch. An error
+// stops the training and is returned by Train.
+type Callback interface {
+	OnTrainBegin(m Model, logs Logs) error
+	OnTrainEnd(logs Logs) error
+	OnEpochBegin(epoch int, logs Logs) error
+	OnEpochEnd(epoch int, logs Logs) error
+	OnBatchBegin(batch int, logs Logs) error
+	OnBatchEnd(batch int, logs Logs) error
+}
+
+// BaseCallback ignores every event, callbacks can embed it and implement
+// only the events they need.
+type BaseCallback struct{}
+
+func (BaseCallback) OnTrainBegin(Model, Logs) error {
+	return nil
+}
+
+func (BaseCallback) OnTrainEnd(Logs) error {
+	return nil
+}
+
+func (BaseCallback) OnEpochBegin(int, Logs) error {
+	return nil
+}
+
+func (BaseCallback) OnEpochEnd(int, Logs) error {
+	return nil
+}
+
+func (BaseCallback) OnBatchBegin(int, Logs) error {
+	return nil
+}
+
+func (BaseCallback) OnBatchEnd(int, Logs) error {
+	return nil
+}
+
+// notify calls event for every callback until one of them fails.
+func notify(callbacks []Callback, event func(c Callback) error) error {
+	for _, c := range callbacks {
+		err := event(c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Clipping limits the gradients of a training step before the weights are
+// updated. Every option is disabled while it is 0, and the enabled ones are
+// applied in order: ClipValue bounds every gradient to [-ClipValue,
+// ClipValue], ClipNorm rescales the gradients of every layer whose L2 norm
+// is above it, and ClipGlobalNorm rescales all the gradients together when
+// their joint L2 norm is above it.
+type Clipping struct {
+	ClipValue      float64
+	ClipNorm       float64
+	ClipGlobalNorm float64
+}
+
+// gradNorm returns the L2 norm of all the given gradients together.
+func gradNorm(grads ...[]tensor.Tensor) float64 {
+	sum := 0.0
+	for _, group := range grads {
+		for _, g := range group {
+			for _, v := range g.GetData() {
+				sum += v * v
+			}
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// scaleGrads multiplies the gradients by scale in place.
+func scaleGrads(scale float64, grads ...[]tensor.Tensor) {
+	for _, group := range grads {
+		for _, g := range group {
+			data := g.GetData()
+			for i := range data {
+				data[i] *= scale
+			}
+		}
+	}
+}
+
+// clip applies the enabled options to the gradients of the layers in place.
+func (c *Clipping) clip(grads [][]tensor.Tensor) {
+	if c.ClipValue > 0 {
+		for _, group := range grads {
+			for _, g := range group {
+				data := g.GetData()
+				for i, v := range data {
+					if v > c.ClipValue {
+						data[i] = c.ClipValue
+					} else if v < -c.ClipValue {
+						data[i] = -c.ClipValue
+					}
+				}
+			}
+		}
+	}
+	if c.ClipNorm > 0 {
+		for _, group := range grads {
+			norm := gradNorm(group)
+			if norm > c.ClipNorm {
+				scaleGrads(c.ClipNorm/norm, group)
+			}
+		}
+	}
+	if c.ClipGlobalNorm > 0 {
+		norm := gradNorm(grads...)
+		if norm > c.ClipGlobalNorm {
+			scaleGrads(c.ClipGlobalNorm/norm, grads...)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// EpochLog is the record of an epoch of Train: its number, counted along all
+// the Train calls of the model, its logs and how long it took. Partial is
+// set if the training stopped before the end of the epoch, which then has
+// the "partial" log and no validation.
+type EpochLog struct {
+	Epoch    int
+	Logs     Logs
+	Duration time.Duration
+	Partial  bool
+}
+
+// History is the record of a Train call, one EpochLog per trained epoch.
+type History struct {
+	Epochs   []EpochLog
+	Duration time.Duration
+}
+
+// Values returns the value of the given log in every epoch, NaN where it is
+// missing.
+func (h *History) Values(key string) []float64 {
+	values := make([]float64, len(h.Epochs))
+	for i, e := range h.Epochs {
+		v, ok := e.Logs[key]
+		if !ok {
+			v = nan
+		}
+		values[i] = v
+	}
+	return values
+}
+
+// Loss returns the loss of the last epoch, NaN if no epoch was trained.
+func (h *History) Loss() float64 {
+	if len(h.Epochs) == 0 {
+		return nan
+	}
+	return h.Epochs[len(h.Epochs)-1].Logs["loss"]
+}
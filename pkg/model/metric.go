@@ -0,0 +1,39 @@
+package model
+
+import "github.com/julioguillermo/neuralnetwork/pkg/tensor"
+
+// Metric measures the outputs of a model against their targets, accumulated
+// over the samples given to Update since the last Reset. Name is the key of
+// its Result in the logs.
+type Metric interface {
+	Name() string
+	Reset()
+	Update(output, target tensor.Tensor) error
+	Result() float64
+}
+
+// resetMetrics resets every metric.
+func resetMetrics(metrics []Metric) {
+	for _, m := range metrics {
+		m.Reset()
+	}
+}
+
+// updateMetrics updates every metric with an output and its target.
+func updateMetrics(metrics []Metric, output, target tensor.Tensor) error {
+	for _, m := range metrics {
+		err := m.Update(output, target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMetrics adds the result of every metric to logs, with its name after
+// prefix.
+func addMetrics(logs Logs, prefix string, metrics []Metric) {
+	for _, m := range metrics {
+		logs[prefix+m.Name()] = m.Result()
+	}
+}
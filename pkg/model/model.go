@@ -1,6 +1,9 @@
 package model
 
 import (
+	"context"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/data"
 	"github.com/julioguillermo/neuralnetwork/pkg/layer"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
@@ -9,12 +12,19 @@ import (
 type Model interface {
 	AddLayer(layer.Layer) error
 	Predict(tensor.Tensor) (tensor.Tensor, error)
-	Train(inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (float64, error)
+	Train(inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (*History, error)
+	TrainContext(ctx context.Context, inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (*History, error)
+	TrainDataset(ctx context.Context, ds data.Dataset, alpha, momentum float64, epochs, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error)) (*History, error)
 	TrainOne(input, target tensor.Tensor, alpha, momentum float64, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error)) (tensor.Tensor, error)
+	Evaluate(inputs, targets []tensor.Tensor, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), metrics []Metric) (Logs, error)
+	EvaluateDataset(ds data.Dataset, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), metrics []Metric) (Logs, error)
 	FullReset() error
+	StopTraining()
 
 	SetTrainable(bool)
 
 	GetModelWeights() (serialization.Weights, error)
 	SetModelWeights(w serialization.Weights) error
+	GetCheckpoint() (serialization.Checkpoint, error)
+	SetCheckpoint(c serialization.Checkpoint) error
 }
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var nan = math.NaN()
+
+// NewReporter returns the reporter Train uses for a verbose level when the
+// model has no Reporter: silent for 0, a line per epoch for 1 and a
+// progress bar for 2.
+func NewReporter(verbose int) Callback {
+	switch verbose {
+	case 1:
+		return NewLineReporter(os.Stdout)
+	case 2:
+		return NewProgressReporter(os.Stdout)
+	}
+	return NewSilentReporter()
+}
+
+// formatLogs writes the logs sorted by name, loss first.
+func formatLogs(logs Logs) string {
+	keys := make([]string, 0, len(logs))
+	for k := range logs {
+		if k != "loss" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := logs["loss"]; ok {
+		keys = append([]string{"loss"}, keys...)
+	}
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s: %.6g", k, logs[k])
+	}
+	return strings.Join(parts, " - ")
+}
+
+// trainProgress keeps the count of the epochs of a Train call for the
+// reporters.
+type trainProgress struct {
+	epochs  int
+	batches int
+	epoch   int
+	start   time.Time
+}
+
+func (tp *trainProgress) OnTrainBegin(m Model, logs Logs) error {
+	tp.epochs = int(logs["epochs"])
+	tp.batches = int(logs["batches"])
+	tp.epoch = 0
+	return nil
+}
+
+func (tp *trainProgress) OnEpochBegin(epoch int, logs Logs) error {
+	tp.epoch++
+	tp.start = time.Now()
+	return nil
+}
+
+// SilentReporter reports nothing.
+type SilentReporter struct {
+	BaseCallback
+}
+
+func NewSilentReporter() *SilentReporter {
+	return &SilentReporter{}
+}
+
+// LineReporter writes a line with the logs of every epoch.
+type LineReporter struct {
+	Writer io.Writer
+
+	BaseCallback
+	trainProgress
+}
+
+func NewLineReporter(w io.Writer) *LineReporter {
+	return &LineReporter{Writer: w}
+}
+
+func (lr *LineReporter) OnTrainBegin(m Model, logs Logs) error {
+	return lr.trainProgress.OnTrainBegin(m, logs)
+}
+
+func (lr *LineReporter) OnEpochBegin(epoch int, logs Logs) error {
+	return lr.trainProgress.OnEpochBegin(epoch, logs)
+}
+
+func (lr *LineReporter) OnEpochEnd(epoch int, logs Logs) error {
+	_, err := fmt.Fprintf(lr.Writer, "Epoch %d/%d - %s - %s\n", lr.epoch, lr.epochs, time.Since(lr.start).Round(time.Millisecond), formatLogs(logs))
+	return err
+}
+
+// ProgressReporter draws a progress bar of the batches of every epoch with
+// the logs of the last one, and leaves a line with the logs of the epoch.
+type ProgressReporter struct {
+	Writer io.Writer
+	Width  int
+
+	BaseCallback
+	trainProgress
+	done int
+}
+
+func NewProgressReporter(w io.Writer) *ProgressReporter {
+	return &ProgressReporter{
+		Writer: w,
+		Width:  30,
+	}
+}
+
+func (pr *ProgressReporter) OnTrainBegin(m Model, logs Logs) error {
+	return pr.trainProgress.OnTrainBegin(m, logs)
+}
+
+func (pr *ProgressReporter) OnEpochBegin(epoch int, logs Logs) error {
+	pr.done = 0
+	err := pr.trainProgress.OnEpochBegin(epoch, logs)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(pr.Writer, "Epoch %d/%d\n", pr.epoch, pr.epochs)
+	return err
+}
+
+// bar returns the count of the trained batches with a bar of the epoch, or
+// only the count when the number of batches is unknown.
+func (pr *ProgressReporter) bar(done int) string {
+	if pr.batches <= 0 {
+		return fmt.Sprint(done)
+	}
+	fill := done * pr.Width / pr.batches
+	if fill > pr.Width {
+		fill = pr.Width
+	}
+	return fmt.Sprintf("%d/%d [%s%s]", done, pr.batches, strings.Repeat("=", fill), strings.Repeat(" ", pr.Width-fill))
+}
+
+func (pr *ProgressReporter) OnBatchEnd(batch int, logs Logs) error {
+	pr.done = batch + 1
+	_, err := fmt.Fprintf(pr.Writer, "\r%s %s\033[K", pr.bar(pr.done), formatLogs(logs))
+	return err
+}
+
+func (pr *ProgressReporter) OnEpochEnd(epoch int, logs Logs) error {
+	_, err := fmt.Fprintf(pr.Writer, "\r%s %s - %s\033[K\n", pr.bar(pr.done), time.Since(pr.start).Round(time.Millisecond), formatLogs(logs))
+	return err
+}
+
+// JSONReporter writes a JSON object per line for the end of every epoch
+// and, with Batches, of every batch. NaN and infinite logs are written as
+// null.
+type JSONReporter struct {
+	Writer  io.Writer
+	Batches bool
+
+	BaseCallback
+	trainProgress
+}
+
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{Writer: w}
+}
+
+func (jr *JSONReporter) write(event string, index int, logs Logs) error {
+	record := map[string]interface{}{
+		"event": event,
+		"epoch": jr.epoch,
+	}
+	if event == "batch" {
+		record["batch"] = index
+	} else {
+		record["duration"] = time.Since(jr.start).Seconds()
+	}
+	for k, v := range logs {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			record[k] = nil
+		} else {
+			record[k] = v
+		}
+	}
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = jr.Writer.Write(append(bytes, '\n'))
+	return err
+}
+
+func (jr *JSONReporter) OnTrainBegin(m Model, logs Logs) error {
+	return jr.trainProgress.OnTrainBegin(m, logs)
+}
+
+func (jr *JSONReporter) OnEpochBegin(epoch int, logs Logs) error {
+	return jr.trainProgress.OnEpochBegin(epoch, logs)
+}
+
+func (jr *JSONReporter) OnBatchEnd(batch int, logs Logs) error {
+	if !jr.Batches {
+		return nil
+	}
+	return jr.write("batch", batch, logs)
+}
+
+func (jr *JSONReporter) OnEpochEnd(epoch int, logs Logs) error {
+	return jr.write("epoch", epoch, logs)
+}
@@ -1,12 +1,16 @@
 package model
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"math/rand"
+	"io"
+	"time"
 
 	"github.com/julioguillermo/neuralnetwork/pkg/activation"
+	"github.com/julioguillermo/neuralnetwork/pkg/data"
 	"github.com/julioguillermo/neuralnetwork/pkg/layer"
+	"github.com/julioguillermo/neuralnetwork/pkg/random"
+	"github.com/julioguillermo/neuralnetwork/pkg/schedule"
 	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
 	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
 )
@@ -17,6 +21,54 @@ type Sequential struct {
 	OutLayer layer.Layer
 
 	Trainable bool
+
+	// Rand shuffles the samples in Train, the shared source of package
+	// random is used if it is nil.
+	Rand random.Source
+
+	// Clipping limits the gradients of every TrainOne, GradNorm is the
+	// global norm of the gradients of the last one before clipping them.
+	Clipping
+	GradNorm float64
+
+	// Schedule changes the learning rate of Train along the training, which
+	// keeps alpha when it is nil. Step and Epoch count the samples and
+	// epochs trained by Train, and Rate is the learning rate of the last
+	// step.
+	Schedule schedule.Schedule
+	Step     int
+	Epoch    int
+	Rate     float64
+
+	// Callbacks are notified of the events of Train, after them the
+	// Reporter writes its progress.
+	Callbacks []Callback
+	Reporter  Callback
+
+	// MaxSteps and MaxDuration are budgets of the samples and time of a
+	// Train call, which stops after the step that spends them. They are
+	// disabled while they are 0.
+	MaxSteps    int
+	MaxDuration time.Duration
+
+	// Metrics are measured on the samples of every epoch of Train and on
+	// its validation data, which is ValidationData, ValidationInputs and
+	// ValidationTargets or, without them, the last ValidationSplit fraction
+	// of the samples.
+	// Their results and the validation loss are in the logs of the epochs,
+	// with "val_" before the name for the validation data.
+	Metrics           []Metric
+	ValidationData    data.Dataset
+	ValidationInputs  []tensor.Tensor
+	ValidationTargets []tensor.Tensor
+	ValidationSplit   float64
+
+	// penalty is the penalty of the regularizers in the last TrainOne.
+	penalty float64
+	// output is the output of the model in the last TrainOne.
+	output tensor.Tensor
+	// stop is set by StopTraining to end Train.
+	stop bool
 }
 
 func NewSequential() *Sequential {
@@ -117,6 +169,12 @@ func (sequential *Sequential) SetTrainable(t bool) {
 	sequential.Trainable = t
 }
 
+// SetTraining switches the layers between training and inference mode.
+// Predict and TrainOne select the mode by themselves.
+func (sequential *Sequential) SetTraining(t bool) {
+	sequential.OutLayer.SetTraining(t)
+}
+
 func (sequential *Sequential) Fit(alpha float64, momentum float64) error {
 	if sequential.Trainable {
 		return sequential.OutLayer.Fit(alpha, momentum)
@@ -126,6 +184,19 @@ func (sequential *Sequential) Fit(alpha float64, momentum float64) error {
 	return nil
 }
 
+func (sequential *Sequential) GetPenalty() (float64, error) {
+	return sequential.OutLayer.GetPenalty()
+}
+
+func (sequential *Sequential) GetGradients() ([][]tensor.Tensor, error) {
+	if sequential.Trainable {
+		return sequential.OutLayer.GetGradients()
+	} else if sequential.PreLayer != nil {
+		return sequential.PreLayer.GetGradients()
+	}
+	return nil, nil
+}
+
 func (sequential *Sequential) ResetSL() error {
 	return sequential.OutLayer.ResetSL()
 }
@@ -167,81 +238,318 @@ func (sequential *Sequential) Predict(input tensor.Tensor) (tensor.Tensor, error
 	if e != nil {
 		return nil, e
 	}
+	sequential.SetTraining(false)
 	sequential.OutLayer.Reset()
 	return sequential.OutLayer.Output(input)
 }
 
-func (sequential *Sequential) Train(inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (float64, error) {
-	e := sequential.setSubPrelayer(sequential.PreLayer)
-	if e != nil {
-		return -1, e
-	}
-	if len(inputs) != len(targets) {
-		return -1, errors.New("inputs and targets len are different")
-	}
-
-	tmp := make([]tensor.Tensor, len(inputs))
-	copy(tmp, inputs)
-	inputs = tmp
-	tmp = make([]tensor.Tensor, len(targets))
-	copy(tmp, targets)
-	targets = tmp
-
-	var bLoss tensor.Tensor
-	var pLoss float64
-	var err error
-	if batch < 1 || len(inputs) < batch {
-		batch = len(inputs)
-	}
-	for epoch := 1; epoch <= epochs; epoch++ {
-		pLoss = 0
-		if shuffle {
-			rand.Shuffle(len(inputs), func(i, j int) {
-				inputs[i], inputs[j] = inputs[j], inputs[i]
-				targets[i], targets[j] = targets[j], targets[i]
-			})
+// Train trains the model with epochs passes over the first batch samples
+// (all of them if batch is 0) and returns the History of the epochs. The
+// progress is written by the Reporter of the model or, without it, by the
+// one NewReporter gives for verbose.
+func (sequential *Sequential) Train(inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (*History, error) {
+	return sequential.TrainContext(context.Background(), inputs, targets, alpha, momentum, epochs, batch, verbose, loss, shuffle)
+}
+
+// TrainContext is Train stopping between two batches when ctx is done, in
+// which case it returns the History of the trained epochs, the last one
+// maybe Partial, with the error of ctx. The model is
+// left as after a full step, ready to be saved or trained again.
+func (sequential *Sequential) TrainContext(ctx context.Context, inputs, targets []tensor.Tensor, alpha, momentum float64, epochs, batch, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), shuffle bool) (*History, error) {
+	slice, err := data.NewSlice(inputs, targets)
+	if err != nil {
+		return &History{}, err
+	}
+	ds, val, err := sequential.validation(slice)
+	if err != nil {
+		return &History{}, err
+	}
+	if shuffle {
+		ds = data.Shuffle(ds, 0, sequential.Rand)
+	}
+	if batch > 0 {
+		ds = data.Take(ds, batch)
+	}
+	return sequential.train(ctx, ds, val, alpha, momentum, epochs, verbose, loss)
+}
+
+// TrainDataset is TrainContext with the samples of a dataset, a pass over
+// it per epoch. A ValidationSplit needs an Indexed dataset.
+func (sequential *Sequential) TrainDataset(ctx context.Context, ds data.Dataset, alpha, momentum float64, epochs, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error)) (*History, error) {
+	ds, val, err := sequential.validation(ds)
+	if err != nil {
+		return &History{}, err
+	}
+	return sequential.train(ctx, ds, val, alpha, momentum, epochs, verbose, loss)
+}
+
+// validation splits the samples of ds from the validation data.
+func (sequential *Sequential) validation(ds data.Dataset) (data.Dataset, data.Dataset, error) {
+	if sequential.ValidationData != nil {
+		return ds, sequential.ValidationData, nil
+	}
+	if sequential.ValidationInputs != nil || sequential.ValidationTargets != nil {
+		val, err := data.NewSlice(sequential.ValidationInputs, sequential.ValidationTargets)
+		if err != nil {
+			return nil, nil, errors.New("validation inputs and targets len are different")
+		}
+		return ds, val, nil
+	}
+	if sequential.ValidationSplit > 0 {
+		indexed, ok := ds.(data.Indexed)
+		if !ok {
+			return nil, nil, errors.New("validation split needs an indexed dataset")
+		}
+		split := indexed.Len() - int(float64(indexed.Len())*sequential.ValidationSplit)
+		if split < 1 || split == indexed.Len() {
+			return nil, nil, errors.New("invalid validation split")
+		}
+		return data.Take(ds, split), data.Skip(ds, split), nil
+	}
+	return ds, nil, nil
+}
+
+func (sequential *Sequential) train(ctx context.Context, ds, val data.Dataset, alpha, momentum float64, epochs, verbose int, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error)) (*History, error) {
+	history := &History{}
+	err := sequential.setSubPrelayer(sequential.PreLayer)
+	if err != nil {
+		return history, err
+	}
+	reporter := sequential.Reporter
+	if reporter == nil {
+		reporter = NewReporter(verbose)
+	}
+	callbacks := append(append([]Callback{}, sequential.Callbacks...), reporter)
+	sequential.stop = false
+	start := time.Now()
+	defer func() {
+		history.Duration = time.Since(start)
+	}()
+	logs := Logs{"epochs": float64(epochs), "batches": float64(data.Len(ds))}
+	err = notify(callbacks, func(c Callback) error { return c.OnTrainBegin(sequential, logs) })
+	if err != nil {
+		return history, err
+	}
+	logs = Logs{}
+	steps := 0
+	for epoch := 1; epoch <= epochs && !sequential.stop && ctx.Err() == nil; epoch++ {
+		epochStart := time.Now()
+		err = notify(callbacks, func(c Callback) error { return c.OnEpochBegin(sequential.Epoch, Logs{}) })
+		if err != nil {
+			return history, err
+		}
+		pLoss, trained, complete, err := sequential.trainEpoch(ctx, ds, alpha, momentum, loss, callbacks, &steps, start)
+		if err != nil {
+			return history, err
 		}
-		if verbose == 2 {
-			fmt.Printf("\n\nEpoch: %d / %d [%.2f%%]\n", epoch, epochs, float64(epoch)/float64(epochs)*100.0)
+		if trained == 0 {
+			break
 		}
-		for i := 0; i < batch; i++ {
-			bLoss, err = sequential.TrainOne(inputs[i], targets[i], alpha, momentum, loss)
+		logs = Logs{"loss": pLoss, "rate": sequential.Rate}
+		addMetrics(logs, "", sequential.Metrics)
+		if !complete {
+			// An interrupted epoch is kept in the history, but the schedule
+			// and the callbacks only see whole epochs.
+			logs["partial"] = 1
+			history.Epochs = append(history.Epochs, EpochLog{
+				Epoch:    sequential.Epoch,
+				Logs:     logs,
+				Duration: time.Since(epochStart),
+				Partial:  true,
+			})
+			break
+		}
+		if val != nil {
+			vLogs, err := sequential.EvaluateDataset(val, loss, sequential.Metrics)
 			if err != nil {
-				return -1, err
+				return history, err
 			}
-			if verbose == 2 {
-				bLoss = bLoss.Abs()
-				pLoss = bLoss.Sum() / float64(bLoss.Size())
-				fmt.Printf("\rBatch: %d / %d [%.2f%%] => ( Loss: %f )", i, batch, float64(i+1)/float64(batch)*100.0, pLoss)
-			} else {
-				bLoss = bLoss.Abs()
-				pLoss += bLoss.Sum() / float64(bLoss.Size())
+			for k, v := range vLogs {
+				logs["val_"+k] = v
 			}
 		}
-		pLoss /= float64(batch)
-		if verbose == 1 {
-			fmt.Printf("\rEpoch: %d / %d [%.2f%%] => ( Loss: %s )", epoch, epochs, float64(epoch)/float64(epochs)*100.0, fmt.Sprint(pLoss))
+		if sequential.Schedule != nil {
+			sequential.Schedule.EpochEnd(sequential.Epoch, logs)
+		}
+		err = notify(callbacks, func(c Callback) error { return c.OnEpochEnd(sequential.Epoch, logs) })
+		if err != nil {
+			return history, err
 		}
+		history.Epochs = append(history.Epochs, EpochLog{
+			Epoch:    sequential.Epoch,
+			Logs:     logs,
+			Duration: time.Since(epochStart),
+		})
+		sequential.Epoch++
 	}
-	fmt.Println()
-	return pLoss, nil
+	err = notify(callbacks, func(c Callback) error { return c.OnTrainEnd(logs) })
+	if err != nil {
+		return history, err
+	}
+	return history, ctx.Err()
+}
+
+// trainEpoch trains the model with a pass over ds and returns its mean loss,
+// the number of samples trained and whether they were all the samples of
+// ds, which they are not when the training is stopped. steps counts the
+// samples of the Train call, which started at start.
+func (sequential *Sequential) trainEpoch(ctx context.Context, ds data.Dataset, alpha, momentum float64, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), callbacks []Callback, steps *int, start time.Time) (pLoss float64, trained int, complete bool, err error) {
+	it := ds.Iter()
+	defer func() {
+		if cErr := it.Close(); err == nil {
+			err = cErr
+		}
+	}()
+	resetMetrics(sequential.Metrics)
+	for i := 0; !sequential.stop && ctx.Err() == nil; i++ {
+		sample, err := it.Next()
+		if err == io.EOF {
+			complete = true
+			break
+		}
+		if err != nil {
+			return 0, trained, false, err
+		}
+		sequential.Rate = alpha
+		if sequential.Schedule != nil {
+			sequential.Rate = sequential.Schedule.Rate(alpha, sequential.Step, sequential.Epoch)
+		}
+		err = notify(callbacks, func(c Callback) error { return c.OnBatchBegin(i, Logs{"rate": sequential.Rate}) })
+		if err != nil {
+			return 0, trained, false, err
+		}
+		bLoss, err := sequential.TrainOne(sample.Input, sample.Target, sequential.Rate, momentum, loss)
+		if err != nil {
+			return 0, trained, false, err
+		}
+		sequential.Step++
+		*steps++
+		trained++
+		err = updateMetrics(sequential.Metrics, sequential.output, sample.Target)
+		if err != nil {
+			return 0, trained, false, err
+		}
+		bLoss = bLoss.Abs()
+		sLoss := bLoss.Sum()/float64(bLoss.Size()) + sequential.penalty
+		pLoss += sLoss
+		batchLogs := Logs{"loss": sLoss, "rate": sequential.Rate, "grad_norm": sequential.GradNorm}
+		err = notify(callbacks, func(c Callback) error { return c.OnBatchEnd(i, batchLogs) })
+		if err != nil {
+			return 0, trained, false, err
+		}
+		if sequential.MaxSteps > 0 && *steps >= sequential.MaxSteps ||
+			sequential.MaxDuration > 0 && time.Since(start) >= sequential.MaxDuration {
+			sequential.stop = true
+		}
+	}
+	if !complete {
+		// the training can stop right after the last sample
+		_, err := it.Next()
+		complete = err == io.EOF
+	}
+	if trained > 0 {
+		pLoss /= float64(trained)
+	}
+	return pLoss, trained, complete, nil
+}
+
+// Evaluate returns the mean loss of the model over the samples, with the
+// penalty of its regularizers, and the results of the metrics, without
+// updating its weights.
+func (sequential *Sequential) Evaluate(inputs, targets []tensor.Tensor, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), metrics []Metric) (Logs, error) {
+	ds, err := data.NewSlice(inputs, targets)
+	if err != nil {
+		return nil, err
+	}
+	return sequential.EvaluateDataset(ds, loss, metrics)
+}
+
+// EvaluateDataset is Evaluate with the samples of a dataset.
+func (sequential *Sequential) EvaluateDataset(ds data.Dataset, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error), metrics []Metric) (logs Logs, err error) {
+	e := sequential.setSubPrelayer(sequential.PreLayer)
+	if e != nil {
+		return nil, e
+	}
+	it := ds.Iter()
+	defer func() {
+		if cErr := it.Close(); err == nil {
+			err = cErr
+		}
+	}()
+	sequential.SetTraining(false)
+	resetMetrics(metrics)
+	total := 0.0
+	count := 0
+	for {
+		sample, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sequential.OutLayer.Reset()
+		out, err := sequential.OutLayer.Output(sample.Input)
+		if err != nil {
+			return nil, err
+		}
+		err = updateMetrics(metrics, out, sample.Target)
+		if err != nil {
+			return nil, err
+		}
+		out, err = loss(out, sample.Target)
+		if err != nil {
+			return nil, err
+		}
+		penalty, err := sequential.OutLayer.GetPenalty()
+		if err != nil {
+			return nil, err
+		}
+		out = out.Abs()
+		total += out.Sum()/float64(out.Size()) + penalty
+		count++
+	}
+	logs = Logs{"loss": nan}
+	if count > 0 {
+		logs["loss"] = total / float64(count)
+	}
+	addMetrics(logs, "", metrics)
+	return logs, nil
+}
+
+// StopTraining makes Train return after the current batch, callbacks call it
+// to end the training early.
+func (sequential *Sequential) StopTraining() {
+	sequential.stop = true
 }
 
 func (sequential *Sequential) TrainOne(input, target tensor.Tensor, alpha, momentum float64, loss func(outputs, targets tensor.Tensor) (tensor.Tensor, error)) (tensor.Tensor, error) {
+	sequential.SetTraining(true)
 	sequential.OutLayer.Reset()
 	out, err := sequential.OutLayer.Output(input)
 	if err != nil {
 		return nil, err
 	}
+	sequential.output = out
 	out, err = loss(out, target)
 	if err != nil {
 		return nil, err
 	}
+	sequential.penalty, err = sequential.OutLayer.GetPenalty()
+	if err != nil {
+		return nil, err
+	}
 	sequential.OutLayer.SetDif(out)
 	err = sequential.OutLayer.Dif()
 	if err != nil {
 		return nil, err
 	}
+	grads, err := sequential.OutLayer.GetGradients()
+	if err != nil {
+		return nil, err
+	}
+	sequential.GradNorm = gradNorm(grads...)
+	sequential.clip(grads)
 	err = sequential.OutLayer.Fit(alpha, momentum)
 	if err != nil {
 		return nil, err
@@ -261,6 +569,40 @@ func (sequential *Sequential) GetModelWeights() (serialization.Weights, error) {
 	return sequential.GetWeights()
 }
 
+// GetCheckpoint returns the weights of the model with the state of the
+// schedule and the step and epoch counters, to resume the training later.
+func (sequential *Sequential) GetCheckpoint() (serialization.Checkpoint, error) {
+	w, err := sequential.GetModelWeights()
+	if err != nil {
+		return serialization.Checkpoint{}, err
+	}
+	c := serialization.Checkpoint{
+		Weights: w,
+		Step:    sequential.Step,
+		Epoch:   sequential.Epoch,
+	}
+	if sequential.Schedule != nil {
+		c.Schedule = sequential.Schedule.GetState()
+	}
+	return c, nil
+}
+
+func (sequential *Sequential) SetCheckpoint(c serialization.Checkpoint) error {
+	err := sequential.SetModelWeights(c.Weights)
+	if err != nil {
+		return err
+	}
+	if sequential.Schedule != nil {
+		err = sequential.Schedule.SetState(c.Schedule)
+		if err != nil {
+			return err
+		}
+	}
+	sequential.Step = c.Step
+	sequential.Epoch = c.Epoch
+	return nil
+}
+
 func (sequential *Sequential) SetModelWeights(w serialization.Weights) error {
 	err := sequential.setSubPrelayer(sequential.PreLayer)
 	if err != nil {
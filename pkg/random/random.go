@@ -0,0 +1,93 @@
+package random
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Source is what the library draws its random numbers from. A *rand.Rand
+// is a Source.
+type Source interface {
+	Float64() float64
+	NormFloat64() float64
+	Intn(n int) int
+	Shuffle(n int, swap func(i, j int))
+}
+
+// locked makes a rand.Rand safe for concurrent use.
+type locked struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *locked) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *locked) NormFloat64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.NormFloat64()
+}
+
+func (l *locked) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *locked) Shuffle(n int, swap func(i, j int)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.r.Shuffle(n, swap)
+}
+
+func (l *locked) seed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.r = rand.New(rand.NewSource(seed))
+}
+
+// global is the Source used by the components that have no Source of their
+// own. It is seeded from the clock until Seed is called.
+var global = &locked{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// Seed makes the Source shared by the library, used by the package functions
+// and by the components without a Source, produce the same numbers on every
+// run.
+func Seed(seed int64) {
+	global.seed(seed)
+}
+
+// New returns a Source of its own seeded with seed. It is not safe for
+// concurrent use.
+func New(seed int64) Source {
+	return rand.New(rand.NewSource(seed))
+}
+
+// Or returns src, or the shared Source if src is nil.
+func Or(src Source) Source {
+	if src == nil {
+		return global
+	}
+	return src
+}
+
+func Float64() float64 {
+	return global.Float64()
+}
+
+func NormFloat64() float64 {
+	return global.NormFloat64()
+}
+
+func Intn(n int) int {
+	return global.Intn(n)
+}
+
+func Shuffle(n int, swap func(i, j int)) {
+	global.Shuffle(n, swap)
+}
@@ -0,0 +1,56 @@
+package regularizer
+
+import (
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/tensor"
+)
+
+// Regularizer is a penalty on the values of a tensor that is added to the
+// loss of a model.
+type Regularizer interface {
+	Penalty(t tensor.Tensor) float64
+	// Gradient returns the gradient of the penalty at t.
+	Gradient(t tensor.Tensor) tensor.Tensor
+}
+
+// L1L2 is the sum of L1 times the absolute values of a tensor and L2 times
+// their squares. With both factors set it is an elastic net.
+type L1L2 struct {
+	L1 float64
+	L2 float64
+}
+
+func NewL1(l1 float64) *L1L2 {
+	return &L1L2{L1: l1}
+}
+
+func NewL2(l2 float64) *L1L2 {
+	return &L1L2{L2: l2}
+}
+
+func NewL1L2(l1, l2 float64) *L1L2 {
+	return &L1L2{L1: l1, L2: l2}
+}
+
+func (reg *L1L2) Penalty(t tensor.Tensor) float64 {
+	penalty := 0.0
+	for _, v := range t.GetData() {
+		penalty += reg.L1*math.Abs(v) + reg.L2*v*v
+	}
+	return penalty
+}
+
+func (reg *L1L2) Gradient(t tensor.Tensor) tensor.Tensor {
+	grad := tensor.NewZeroTensor(t.GetShape()...)
+	data := grad.GetData()
+	for i, v := range t.GetData() {
+		data[i] = 2 * reg.L2 * v
+		if v > 0 {
+			data[i] += reg.L1
+		} else if v < 0 {
+			data[i] -= reg.L1
+		}
+	}
+	return grad
+}
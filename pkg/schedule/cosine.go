@@ -0,0 +1,51 @@
+package schedule
+
+import "math"
+
+// CosineRestarts anneals the learning rate from alpha to Min times alpha
+// along a cosine curve of Steps steps, then restarts it. Every cycle is Mult
+// times longer than the previous one and starts at Decay times its peak.
+type CosineRestarts struct {
+	Steps int
+	Mult  float64
+	Min   float64
+	Decay float64
+
+	stateless
+}
+
+func NewCosineRestarts(steps int, mult, min float64) *CosineRestarts {
+	return &CosineRestarts{
+		Steps: steps,
+		Mult:  mult,
+		Min:   min,
+		Decay: 1,
+	}
+}
+
+// NewCosine anneals the learning rate once every steps steps.
+func NewCosine(steps int, min float64) *CosineRestarts {
+	return NewCosineRestarts(steps, 1, min)
+}
+
+func (cr *CosineRestarts) Rate(alpha float64, step, epoch int) float64 {
+	if cr.Steps < 1 {
+		return alpha
+	}
+	period := cr.Steps
+	peak := 1.0
+	for step >= period {
+		step -= period
+		period = int(float64(period) * cr.Mult)
+		if period < 1 {
+			period = 1
+		}
+		peak *= cr.Decay
+	}
+	return alpha * anneal(peak, cr.Min, float64(step)/float64(period))
+}
+
+// anneal goes from start to end along half a cosine as p goes from 0 to 1.
+func anneal(start, end, p float64) float64 {
+	return end + (start-end)*(1+math.Cos(math.Pi*p))/2
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import "math"
+
+// StepDecay multiplies the learning rate by Factor every Epochs epochs.
+type StepDecay struct {
+	Factor float64
+	Epochs int
+
+	stateless
+}
+
+func NewStepDecay(factor float64, epochs int) *StepDecay {
+	return &StepDecay{
+		Factor: factor,
+		Epochs: epochs,
+	}
+}
+
+func (sd *StepDecay) Rate(alpha float64, step, epoch int) float64 {
+	if sd.Epochs < 1 {
+		return alpha
+	}
+	return alpha * math.Pow(sd.Factor, float64(epoch/sd.Epochs))
+}
+
+// ExponentialDecay multiplies the learning rate by Decay every Steps steps,
+// smoothly or, if Staircase is set, at once at the end of them.
+type ExponentialDecay struct {
+	Decay     float64
+	Steps     int
+	Staircase bool
+
+	stateless
+}
+
+func NewExponentialDecay(decay float64, steps int) *ExponentialDecay {
+	return &ExponentialDecay{
+		Decay: decay,
+		Steps: steps,
+	}
+}
+
+func (ed *ExponentialDecay) Rate(alpha float64, step, epoch int) float64 {
+	if ed.Steps < 1 {
+		return alpha
+	}
+	p := float64(step) / float64(ed.Steps)
+	if ed.Staircase {
+		p = math.Floor(p)
+	}
+	return alpha * math.Pow(ed.Decay, p)
+}
@@ -0,0 +1,36 @@
+package schedule
+
+// OneCycle raises the learning rate from alpha/Div to alpha along the first
+// Start fraction of Steps, then anneals it to alpha/(Div*FinalDiv) along the
+// rest of them, both along a cosine curve. alpha is the maximum learning
+// rate and Steps should be the total number of steps of the training.
+type OneCycle struct {
+	Steps    int
+	Start    float64
+	Div      float64
+	FinalDiv float64
+
+	stateless
+}
+
+func NewOneCycle(steps int) *OneCycle {
+	return &OneCycle{
+		Steps:    steps,
+		Start:    0.3,
+		Div:      25,
+		FinalDiv: 1e4,
+	}
+}
+
+func (oc *OneCycle) Rate(alpha float64, step, epoch int) float64 {
+	initial := alpha / oc.Div
+	final := initial / oc.FinalDiv
+	up := int(oc.Start * float64(oc.Steps))
+	if step < up {
+		return anneal(initial, alpha, float64(step)/float64(up))
+	}
+	if step >= oc.Steps {
+		return final
+	}
+	return anneal(alpha, final, float64(step-up)/float64(oc.Steps-up))
+}
@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"errors"
+	"math"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+)
+
+// ReduceOnPlateau multiplies the learning rate by Factor when the Monitor
+// log of the epochs has not improved by more than MinDelta for Patience
+// epochs, and then waits Cooldown epochs before watching it again. The log
+// improves when it decreases or, if Max is set, when it increases. The
+// learning rate is never reduced below MinRate.
+type ReduceOnPlateau struct {
+	Monitor  string
+	Factor   float64
+	Patience int
+	MinDelta float64
+	Cooldown int
+	MinRate  float64
+	Max      bool
+
+	reduced  int
+	best     float64
+	started  bool
+	wait     int
+	cooldown int
+}
+
+func NewReduceOnPlateau(monitor string, factor float64, patience int) *ReduceOnPlateau {
+	return &ReduceOnPlateau{
+		Monitor:  monitor,
+		Factor:   factor,
+		Patience: patience,
+		MinDelta: 1e-4,
+	}
+}
+
+func (rp *ReduceOnPlateau) Rate(alpha float64, step, epoch int) float64 {
+	return math.Max(alpha*math.Pow(rp.Factor, float64(rp.reduced)), rp.MinRate)
+}
+
+func (rp *ReduceOnPlateau) improved(metric float64) bool {
+	if !rp.started {
+		return true
+	}
+	if rp.Max {
+		return metric > rp.best+rp.MinDelta
+	}
+	return metric < rp.best-rp.MinDelta
+}
+
+func (rp *ReduceOnPlateau) EpochEnd(epoch int, logs map[string]float64) {
+	metric, ok := logs[rp.Monitor]
+	if !ok || math.IsNaN(metric) {
+		return
+	}
+	if rp.cooldown > 0 {
+		rp.cooldown--
+		rp.wait = 0
+	}
+	if rp.improved(metric) {
+		rp.best = metric
+		rp.started = true
+		rp.wait = 0
+		return
+	}
+	if rp.cooldown > 0 {
+		return
+	}
+	rp.wait++
+	if rp.wait >= rp.Patience {
+		rp.reduced++
+		rp.cooldown = rp.Cooldown
+		rp.wait = 0
+	}
+}
+
+func (rp *ReduceOnPlateau) GetState() serialization.Weights {
+	started := 0.0
+	if rp.started {
+		started = 1
+	}
+	return serialization.Weights{
+		Data: [][]float64{{float64(rp.reduced), rp.best, started, float64(rp.wait), float64(rp.cooldown)}},
+	}
+}
+
+func (rp *ReduceOnPlateau) SetState(s serialization.Weights) error {
+	if s.Data == nil {
+		return nil
+	}
+	if len(s.Data) != 1 || len(s.Data[0]) != 5 {
+		return errors.New("invalid state")
+	}
+	rp.reduced = int(s.Data[0][0])
+	rp.best = s.Data[0][1]
+	rp.started = s.Data[0][2] != 0
+	rp.wait = int(s.Data[0][3])
+	rp.cooldown = int(s.Data[0][4])
+	return nil
+}
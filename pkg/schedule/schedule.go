@@ -0,0 +1,33 @@
+package schedule
+
+import "github.com/julioguillermo/neuralnetwork/pkg/serialization"
+
+// Schedule gives the learning rate of every training step. alpha is the
+// learning rate passed to Train, step counts the trained samples and epoch
+// the finished epochs, both from 0 and along all the Train calls of a model.
+//
+// EpochEnd is called after every epoch with its logs, such as "loss" and,
+// with validation data, "val_loss". The state a schedule keeps between calls
+// is saved in the checkpoints of the model with GetState and restored with
+// SetState.
+type Schedule interface {
+	Rate(alpha float64, step, epoch int) float64
+	EpochEnd(epoch int, logs map[string]float64)
+
+	GetState() serialization.Weights
+	SetState(serialization.Weights) error
+}
+
+// stateless implements the methods of Schedule that the schedules depending
+// only on the step and epoch do not need.
+type stateless struct{}
+
+func (stateless) EpochEnd(int, map[string]float64) {}
+
+func (stateless) GetState() serialization.Weights {
+	return serialization.Weights{}
+}
+
+func (stateless) SetState(serialization.Weights) error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"errors"
+
+	"github.com/julioguillermo/neuralnetwork/pkg/serialization"
+)
+
+// Warmup raises the learning rate linearly from 0 to alpha along the first
+// Steps steps, then follows After, whose steps are counted from the end of
+// the warmup. Without After the learning rate stays at alpha.
+type Warmup struct {
+	Steps int
+	After Schedule
+}
+
+func NewWarmup(steps int, after Schedule) *Warmup {
+	return &Warmup{
+		Steps: steps,
+		After: after,
+	}
+}
+
+func (w *Warmup) Rate(alpha float64, step, epoch int) float64 {
+	if step < w.Steps {
+		return alpha * float64(step+1) / float64(w.Steps)
+	}
+	if w.After == nil {
+		return alpha
+	}
+	return w.After.Rate(alpha, step-w.Steps, epoch)
+}
+
+func (w *Warmup) EpochEnd(epoch int, logs map[string]float64) {
+	if w.After != nil {
+		w.After.EpochEnd(epoch, logs)
+	}
+}
+
+func (w *Warmup) GetState() serialization.Weights {
+	if w.After == nil {
+		return serialization.Weights{}
+	}
+	return serialization.Weights{
+		PreWeights: []serialization.Weights{w.After.GetState()},
+	}
+}
+
+func (w *Warmup) SetState(s serialization.Weights) error {
+	if w.After == nil || s.PreWeights == nil {
+		return nil
+	}
+	if len(s.PreWeights) != 1 {
+		return errors.New("invalid preWeights len")
+	}
+	return w.After.SetState(s.PreWeights[0])
+}
@@ -0,0 +1,66 @@
+package serialization
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// Checkpoint is the state needed to resume a training: the weights of the
+// model, the state of its learning-rate schedule and the number of steps
+// and epochs it has been trained.
+type Checkpoint struct {
+	Weights  Weights
+	Schedule Weights
+	Step     int
+	Epoch    int
+}
+
+func BinSaveCheckpoint(c Checkpoint, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := gob.NewEncoder(file)
+	return enc.Encode(c)
+}
+
+func BinLoadCheckpoint(path string) (Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer file.Close()
+	dec := gob.NewDecoder(file)
+	var c Checkpoint
+	err = dec.Decode(&c)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return c, nil
+}
+
+func JsonSaveCheckpoint(c Checkpoint, path string) error {
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+func JsonLoadCheckpoint(path string) (Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	var c Checkpoint
+	err = decoder.Decode(&c)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return c, nil
+}
@@ -1,6 +1,25 @@
 package serialization
 
 type Weights struct {
-	Data       [][]float64 `json:data`
-	PreWeights []Weights   `json:pre_weights`
+	Data       [][]float64
+	PreWeights []Weights
+}
+
+// Copy returns a deep copy of the weights. The layers return their live
+// values, which change with every fit, so a copy is needed to keep them.
+func (w Weights) Copy() Weights {
+	out := Weights{}
+	if w.Data != nil {
+		out.Data = make([][]float64, len(w.Data))
+		for i, d := range w.Data {
+			out.Data[i] = append([]float64{}, d...)
+		}
+	}
+	if w.PreWeights != nil {
+		out.PreWeights = make([]Weights, len(w.PreWeights))
+		for i, pw := range w.PreWeights {
+			out.PreWeights[i] = pw.Copy()
+		}
+	}
+	return out
 }
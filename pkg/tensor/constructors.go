@@ -1,6 +1,6 @@
 package tensor
 
-import "math/rand"
+import "github.com/julioguillermo/neuralnetwork/pkg/random"
 
 func NewTensor(data []float64, shape ...int) Tensor {
 	return &NormTensor{
@@ -33,7 +33,7 @@ func NewRandTensor(min float64, max float64, shape ...int) Tensor {
 	data := make([]float64, data_len)
 	scale := max - min
 	for i := 0; i < data_len; i++ {
-		data[i] = rand.Float64()*scale + min
+		data[i] = random.Float64()*scale + min
 	}
 	return NewTensor(data, shape...)
 }